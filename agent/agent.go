@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cathalgarvey/go-minilock"
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// DefaultTTL is how long the agent holds a key when no TTL is given.
+const DefaultTTL = 10 * time.Minute
+
+// Agent holds derived keys and performs operations with them on behalf of
+// clients. The zero value is not usable; create one with New.
+type Agent struct {
+	mu         sync.Mutex
+	entries    []*entry
+	defaultTTL time.Duration
+}
+
+type entry struct {
+	keys     *taber.Keys
+	identity *minilock.IdentityKeys
+	id       Identity
+	timer    *time.Timer
+}
+
+func (e *entry) wipe() {
+	e.timer.Stop()
+	e.keys.Wipe()
+	e.identity.Wipe()
+}
+
+// New returns an Agent that holds keys for defaultTTL unless told otherwise.
+func New(defaultTTL time.Duration) *Agent {
+	if defaultTTL <= 0 {
+		defaultTTL = DefaultTTL
+	}
+	return &Agent{defaultTTL: defaultTTL}
+}
+
+// Add derives the box and identity keys for email and passphrase and holds them
+// for ttl, or the agent's default TTL if ttl is not positive. Adding an identity
+// that is already held simply refreshes its TTL.
+func (a *Agent) Add(email, passphrase string, ttl time.Duration) (*Identity, error) {
+	if ttl <= 0 {
+		ttl = a.defaultTTL
+	}
+	keys, err := minilock.GenerateKey(email, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := minilock.IdentityFromEmailAndPassphrase(email, passphrase)
+	if err != nil {
+		keys.Wipe()
+		return nil, err
+	}
+	e := &entry{keys: keys, identity: identity}
+	e.id.BoxID, err = keys.EncodeID()
+	if err != nil {
+		e.keys.Wipe()
+		e.identity.Wipe()
+		return nil, err
+	}
+	e.id.IdentityID, err = identity.EncodeID()
+	if err != nil {
+		e.keys.Wipe()
+		e.identity.Wipe()
+		return nil, err
+	}
+	e.id.Expires = time.Now().Add(ttl)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The timer is armed with the lock held so it can't fire before e is stored.
+	e.timer = time.AfterFunc(ttl, func() { a.expire(e) })
+	for i, held := range a.entries {
+		if held.id.IdentityID == e.id.IdentityID {
+			held.wipe()
+			a.entries[i] = e
+			id := e.id
+			return &id, nil
+		}
+	}
+	a.entries = append(a.entries, e)
+	id := e.id
+	return &id, nil
+}
+
+func (a *Agent) expire(e *entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, held := range a.entries {
+		if held == e {
+			a.entries = append(a.entries[:i], a.entries[i+1:]...)
+			e.wipe()
+			return
+		}
+	}
+}
+
+// List returns the identities currently held by the agent.
+func (a *Agent) List() []Identity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ids := make([]Identity, 0, len(a.entries))
+	for _, e := range a.entries {
+		ids = append(ids, e.id)
+	}
+	return ids
+}
+
+// Remove wipes and forgets the key held for identityID.
+func (a *Agent) Remove(identityID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, e := range a.entries {
+		if e.id.IdentityID == identityID {
+			a.entries = append(a.entries[:i], a.entries[i+1:]...)
+			e.wipe()
+			return nil
+		}
+	}
+	return ErrUnknownIdentity
+}
+
+// Lock wipes and forgets every key held by the agent.
+func (a *Agent) Lock() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, e := range a.entries {
+		e.wipe()
+	}
+	a.entries = nil
+}
+
+// Decrypt attempts to decrypt a miniLock file with each key held by the agent
+// in turn, returning the result of the first one the file was encrypted to.
+// It is DecryptMessage with the result unpacked.
+func (a *Agent) Decrypt(fileContents []byte) (senderIdentityID, senderID, replyToID, filename string, contents []byte, err error) {
+	msg, err := a.DecryptMessage(fileContents)
+	if err != nil {
+		return "", "", "", "", nil, err
+	}
+	return msg.SenderIdentityID, msg.SenderID, msg.ReplyToID, msg.Filename, msg.Contents, nil
+}
+
+// DecryptMessage decrypts a miniLock file with whichever key held by the agent
+// it was encrypted to; the message's RecipientID tells which.
+func (a *Agent) DecryptMessage(fileContents []byte) (*minilock.Message, error) {
+	header, ciphertext, err := minilock.ParseFileContents(fileContents)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.entries) == 0 {
+		return nil, ErrNoKeys
+	}
+	keys := make([]*taber.Keys, 0, len(a.entries))
+	for _, e := range a.entries {
+		keys = append(keys, e.keys)
+	}
+	return header.DecryptMessageWithKeys(ciphertext, keys)
+}
+
+// Sign signs content with the identity key held for identityID.
+func (a *Agent) Sign(identityID string, content []byte) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, e := range a.entries {
+		if e.id.IdentityID == identityID {
+			return e.identity.Sign(content), nil
+		}
+	}
+	return nil, ErrUnknownIdentity
+}
+
+// Listen creates a Unix socket at path that only the current user may connect
+// to, replacing any stale socket left behind by a previous agent. Where the
+// platform has a umask the socket is created private; the mode is set again
+// afterwards in any case.
+func Listen(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := listenPrivate(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve accepts client connections on l until it is closed.
+func (a *Agent) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.handle(conn)
+	}
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		resp := a.dispatch(&req)
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) dispatch(req *request) *response {
+	var (
+		resp = new(response)
+		err  error
+	)
+	switch req.Op {
+	case opAdd:
+		var id *Identity
+		id, err = a.Add(req.Email, req.Passphrase, time.Duration(req.TTL)*time.Second)
+		if err == nil {
+			resp.Identities = []Identity{*id}
+		}
+	case opList:
+		resp.Identities = a.List()
+	case opRemove:
+		err = a.Remove(req.IdentityID)
+	case opLock:
+		a.Lock()
+	case opDecrypt:
+		var msg *minilock.Message
+		if msg, err = a.DecryptMessage(req.Content); err == nil {
+			resp.SenderIdentityID, resp.SenderID, resp.ReplyToID, resp.Filename, resp.Contents = msg.SenderIdentityID, msg.SenderID, msg.ReplyToID, msg.Filename, msg.Contents
+			resp.RecipientID = msg.RecipientID
+			resp.Verification, resp.Header, resp.Metadata = msg.Verification, &msg.Header, msg.Metadata
+			resp.Compression, resp.Padding = msg.Compression, msg.Padding
+		}
+	case opSign:
+		resp.Signature, err = a.Sign(req.IdentityID, req.Content)
+	default:
+		err = ErrUnknownOp
+	}
+	resp.Error, resp.ErrorType, resp.ErrorCategory = errorToWire(err)
+	return resp
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agl/ed25519"
+	"github.com/cathalgarvey/go-minilock"
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+func Test_AgentRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minilock-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	a := New(time.Minute)
+	go a.Serve(l)
+
+	c, err := Dial(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, _, _, _, _, err = c.Decrypt([]byte("not a minilock file at all"))
+	if !errors.Is(err, minilock.ErrBadMagicBytes) || !errors.Is(err, minilock.ErrMalformed) {
+		t.Error("Expected ErrBadMagicBytes for garbage input, got:", err)
+	}
+
+	id, err := c.Add("cathalgarvey@some.where", "this is a password that totally works for minilock purposes", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0].IdentityID != id.IdentityID {
+		t.Fatal("Agent did not list the added identity:", ids)
+	}
+
+	recipient, err := minilock.ImportID(id.BoxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := minilock.EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := minilock.IdentityFromEmailAndPassphrase("joeblocks@else.where", "whatever I write won't be good enough for the NSA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("Some secret that only the agent can read.")
+	mlfile, err := minilock.EncryptFileContents("secret.txt", plaintext, sender, sender, identity, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	senderIdentityID, _, _, filename, contents, err := c.Decrypt(mlfile)
+	if err != nil {
+		t.Fatal("Agent failed to decrypt: ", err)
+	}
+	identityID, _ := identity.EncodeID()
+	if senderIdentityID != identityID || filename != "secret.txt" || !bytes.Equal(contents, plaintext) {
+		t.Error("Agent decryption returned unexpected results:", senderIdentityID, filename, string(contents))
+	}
+
+	e, err := minilock.NewEncrypter(minilock.WithIdentity(identity), minilock.WithRecipients(recipient), minilock.WithFilename("secret.txt"),
+		minilock.WithMetadata(&minilock.Metadata{ContentType: "text/plain"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withMetadata, _, err := e.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := c.DecryptMessage(withMetadata)
+	if err != nil {
+		t.Fatal("Agent failed to decrypt: ", err)
+	}
+	if msg.Verification != minilock.Signed || msg.Metadata == nil || msg.Metadata.ContentType != "text/plain" || msg.Header.Entries != 1 {
+		t.Error("Agent lost message details:", msg.Verification, msg.Metadata, msg.Header)
+	}
+
+	signature, err := c.Sign(id.IdentityID, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := minilock.IdentityFromID(id.IdentityID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		pk  [ed25519.PublicKeySize]byte
+		sig [ed25519.SignatureSize]byte
+	)
+	copy(pk[:], signer.Public)
+	copy(sig[:], signature)
+	if !ed25519.Verify(&pk, plaintext, &sig) {
+		t.Error("Agent signature did not verify")
+	}
+
+	if err = c.Remove(id.IdentityID); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Remove(id.IdentityID); err != ErrUnknownIdentity {
+		t.Error("Expected ErrUnknownIdentity removing an identity twice, got:", err)
+	}
+	_, _, _, _, _, err = c.Decrypt(mlfile)
+	if err != ErrNoKeys {
+		t.Error("Expected ErrNoKeys after removing the only key, got:", err)
+	}
+}
+
+func Test_ListenPrivate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minilock-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	info, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Error("Agent socket is open to other users: ", perm)
+	}
+}
+
+// Errors with no wire form of their own keep their category.
+func Test_ErrorCategoriesOverWire(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		category error
+	}{
+		{&taber.ChunkAuthError{Index: 2}, minilock.ErrTampered},
+		{minilock.ErrBadFrame, minilock.ErrMalformed},
+		{&minilock.HeaderError{Err: minilock.ErrTooManyRecipients}, minilock.ErrMalformed},
+		{minilock.ErrCannotDecrypt, nil},
+	} {
+		got := errorFromWire(errorToWire(tc.err))
+		if got == nil || got.Error() != tc.err.Error() {
+			t.Error("Expected ", tc.err, " back from the wire, got: ", got)
+		}
+		for _, c := range []error{minilock.ErrMalformed, minilock.ErrTampered} {
+			if errors.Is(got, c) != (c == tc.category) {
+				t.Error("Category of ", tc.err, " changed over the wire: ", got)
+			}
+		}
+	}
+}
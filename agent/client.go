@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cathalgarvey/go-minilock"
+)
+
+// Client talks to a running agent. It is safe for concurrent use; requests
+// are serialised over a single connection.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+// Dial connects to the agent listening on the Unix socket at path; use
+// SocketPath for the default location.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}, nil
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) call(req *request) (*response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+	resp := new(response)
+	if err := c.dec.Decode(resp); err != nil {
+		return nil, err
+	}
+	if err := errorFromWire(resp.Error, resp.ErrorType, resp.ErrorCategory); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Add asks the agent to derive and hold the keys for email and passphrase for
+// ttl; a ttl of zero uses the agent's default.
+func (c *Client) Add(email, passphrase string, ttl time.Duration) (*Identity, error) {
+	resp, err := c.call(&request{Op: opAdd, Email: email, Passphrase: passphrase, TTL: int64(ttl / time.Second)})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Identities) != 1 {
+		return nil, ErrUnknownIdentity
+	}
+	return &resp.Identities[0], nil
+}
+
+// List returns the identities held by the agent.
+func (c *Client) List() ([]Identity, error) {
+	resp, err := c.call(&request{Op: opList})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Identities, nil
+}
+
+// Remove asks the agent to wipe and forget the key held for identityID.
+func (c *Client) Remove(identityID string) error {
+	_, err := c.call(&request{Op: opRemove, IdentityID: identityID})
+	return err
+}
+
+// Lock asks the agent to wipe and forget every key it holds.
+func (c *Client) Lock() error {
+	_, err := c.call(&request{Op: opLock})
+	return err
+}
+
+// Decrypt asks the agent to decrypt a miniLock file with whichever of its keys
+// the file was encrypted to. Return values are as for minilock.DecryptFileContents.
+func (c *Client) Decrypt(fileContents []byte) (senderIdentityID, senderID, replyToID, filename string, contents []byte, err error) {
+	msg, err := c.DecryptMessage(fileContents)
+	if err != nil {
+		return "", "", "", "", nil, err
+	}
+	return msg.SenderIdentityID, msg.SenderID, msg.ReplyToID, msg.Filename, msg.Contents, nil
+}
+
+// DecryptMessage is Decrypt returning a minilock.Message, as for
+// minilock.DecryptMessage.
+func (c *Client) DecryptMessage(fileContents []byte) (*minilock.Message, error) {
+	resp, err := c.call(&request{Op: opDecrypt, Content: fileContents})
+	if err != nil {
+		return nil, err
+	}
+	msg := &minilock.Message{
+		SenderIdentityID: resp.SenderIdentityID,
+		SenderID:         resp.SenderID,
+		ReplyToID:        resp.ReplyToID,
+		RecipientID:      resp.RecipientID,
+		Filename:         resp.Filename,
+		Contents:         resp.Contents,
+		Verification:     resp.Verification,
+		Metadata:         resp.Metadata,
+		Compression:      resp.Compression,
+		Padding:          resp.Padding,
+	}
+	if resp.Header != nil {
+		msg.Header = *resp.Header
+	}
+	return msg, nil
+}
+
+// Sign asks the agent to sign content with the identity key for identityID.
+func (c *Client) Sign(identityID string, content []byte) ([]byte, error) {
+	resp, err := c.call(&request{Op: opSign, IdentityID: identityID, Content: content})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
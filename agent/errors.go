@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"errors"
+
+	"github.com/cathalgarvey/go-minilock"
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+var (
+	// ErrNoKeys is returned when the agent holds no keys to operate with.
+	ErrNoKeys = errors.New("Agent holds no keys")
+	// ErrUnknownIdentity is returned when the requested identity is not held by the agent.
+	ErrUnknownIdentity = errors.New("Agent holds no key for the requested identity")
+	// ErrUnknownOp is returned when the agent receives a request it does not understand.
+	ErrUnknownOp = errors.New("Unknown agent operation")
+	// ErrMlockUnsupported is returned when memory cannot be locked on this platform.
+	ErrMlockUnsupported = errors.New("Locking memory is not supported on this platform")
+)
+
+// Errors that are sent over the wire by name, so that clients get the same
+// sentinel values they would get from the library when operating locally.
+var wireErrors = map[string]error{}
+
+func init() {
+	for _, err := range []error{
+		ErrNoKeys, ErrUnknownIdentity, ErrUnknownOp,
+		minilock.ErrBadMagicBytes, minilock.ErrBadLengthPrefix, minilock.ErrCTHashMismatch,
+		minilock.ErrBadRecipient, minilock.ErrCannotDecrypt, minilock.ErrBadAnonymousEntry,
+		minilock.ErrLegacyFile, minilock.ErrUnsignedEntry,
+	} {
+		wireErrors[err.Error()] = err
+	}
+}
+
+// Typed errors are sent as their type and the message of the error they wrap,
+// or the claimed signer for a *minilock.SignatureError.
+const (
+	errorTypeHeader    = "header"
+	errorTypeSignature = "signature"
+)
+
+// Every error is also sent with its category, if it has one, so that errors
+// with no wire form of their own, such as *taber.ChunkAuthError, still match
+// ErrMalformed or ErrTampered on the client.
+var wireCategories = map[string]error{
+	"malformed": minilock.ErrMalformed,
+	"tampered":  minilock.ErrTampered,
+}
+
+func errorToWire(err error) (msg, errorType, category string) {
+	if err == nil {
+		return "", "", ""
+	}
+	for name, c := range wireCategories {
+		if errors.Is(err, c) {
+			category = name
+		}
+	}
+	var (
+		headerErr *minilock.HeaderError
+		sigErr    *minilock.SignatureError
+	)
+	switch {
+	case errors.As(err, &headerErr):
+		return headerErr.Err.Error(), errorTypeHeader, category
+	case errors.As(err, &sigErr):
+		return sigErr.SignerID, errorTypeSignature, category
+	default:
+		return err.Error(), "", category
+	}
+}
+
+func errorFromWire(msg, errorType, category string) error {
+	switch {
+	case errorType == errorTypeSignature:
+		return &minilock.SignatureError{SignerID: msg}
+	case msg == "":
+		return nil
+	}
+	err, ok := wireErrors[msg]
+	if !ok {
+		err = errors.New(msg)
+	}
+	if errorType == errorTypeHeader {
+		err = &minilock.HeaderError{Err: err}
+	}
+	if c, ok := wireCategories[category]; ok && !errors.Is(err, c) {
+		err = &taber.CategorisedError{Msg: err.Error(), Category: c}
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+/*Package agent - an ssh-agent style daemon and client for holding derived miniLock keys.
+
+Deriving a key from an email and passphrase is deliberately expensive; scrypt eats
+about 128MiB and a second or so of CPU for each derivation. The agent derives keys
+once, holds the resulting taber.Keys and IdentityKeys in memory that is locked
+against swapping (where the platform allows), and forgets them after a TTL.
+
+Clients talk to the agent over a Unix socket, and may ask it to decrypt files or
+sign content on their behalf; private key material never leaves the agent process.
+*/
+package agent
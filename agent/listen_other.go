@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package agent
+
+import "net"
+
+// There is no umask on this platform; Listen restricts the socket afterwards.
+func listenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
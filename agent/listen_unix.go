@@ -0,0 +1,16 @@
+//go:build linux || darwin
+
+package agent
+
+import (
+	"net"
+	"syscall"
+)
+
+// Creates the socket under a umask that leaves it to the current user alone,
+// so that there is no moment when others may connect.
+func listenPrivate(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,9 @@
+package agent
+
+import "syscall"
+
+// LockMemory locks all current and future pages of the process into RAM so
+// that held keys are never written to swap.
+func LockMemory() error {
+	return syscall.Mlockall(syscall.MCL_CURRENT | syscall.MCL_FUTURE)
+}
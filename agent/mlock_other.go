@@ -0,0 +1,8 @@
+//go:build !linux
+
+package agent
+
+// LockMemory is unsupported on this platform and always returns ErrMlockUnsupported.
+func LockMemory() error {
+	return ErrMlockUnsupported
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cathalgarvey/go-minilock"
+)
+
+// SocketEnv is the environment variable consulted for the agent socket path.
+const SocketEnv = "MINILOCK_AGENT_SOCK"
+
+const (
+	opAdd     = "add"
+	opList    = "list"
+	opRemove  = "remove"
+	opLock    = "lock"
+	opDecrypt = "decrypt"
+	opSign    = "sign"
+)
+
+// Identity describes a key pair held by the agent. It never carries private
+// key material.
+type Identity struct {
+	BoxID      string    `json:"boxID"`
+	IdentityID string    `json:"identityID"`
+	Expires    time.Time `json:"expires"`
+}
+
+// Requests and responses are exchanged as one JSON object per line; a client
+// may send any number of requests over a single connection.
+type request struct {
+	Op         string `json:"op"`
+	Email      string `json:"email,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	TTL        int64  `json:"ttl,omitempty"`
+	IdentityID string `json:"identityID,omitempty"`
+	Content    []byte `json:"content,omitempty"`
+}
+
+type response struct {
+	Error            string     `json:"error,omitempty"`
+	ErrorType        string     `json:"errorType,omitempty"`
+	ErrorCategory    string     `json:"errorCategory,omitempty"`
+	Identities       []Identity `json:"identities,omitempty"`
+	SenderIdentityID string     `json:"senderIdentityID,omitempty"`
+	SenderID         string     `json:"senderID,omitempty"`
+	ReplyToID        string     `json:"replyToID,omitempty"`
+	RecipientID      string     `json:"recipientID,omitempty"`
+	Filename         string     `json:"filename,omitempty"`
+	Contents         []byte     `json:"contents,omitempty"`
+	Signature        []byte     `json:"signature,omitempty"`
+	// Verification is omitted for minilock.Signed, its zero value.
+	Verification minilock.Verification `json:"verification,omitempty"`
+	Header       *minilock.HeaderInfo  `json:"header,omitempty"`
+	Metadata     *minilock.Metadata    `json:"metadata,omitempty"`
+	Compression  string                `json:"compression,omitempty"`
+	Padding      string                `json:"padding,omitempty"`
+}
+
+// SocketPath returns the path of the agent socket: the value of SocketEnv if
+// set, otherwise a per-user socket in XDG_RUNTIME_DIR or the temp directory.
+func SocketPath() string {
+	if p := os.Getenv(SocketEnv); p != "" {
+		return p
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "minilock-agent.sock")
+	}
+	return filepath.Join(os.TempDir(), "minilock-agent-"+strconv.Itoa(os.Getuid())+".sock")
+}
@@ -0,0 +1,30 @@
+package minilock
+
+import (
+	"bytes"
+	"encoding/pem"
+)
+
+const fileBlockType = "FMINILOCK FILE"
+
+// Armor encodes a miniLock file as ASCII text, for pasting into email or chat.
+// It is about a third larger than the file; Dearmor reverses it.
+func Armor(miniLockContents []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: fileBlockType, Bytes: miniLockContents})
+}
+
+// IsArmored reports whether contents look like a file encoded by Armor rather
+// than a binary miniLock file.
+func IsArmored(contents []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(contents, " \t\r\n"), []byte("-----BEGIN "+fileBlockType+"-----"))
+}
+
+// Dearmor decodes a file encoded by Armor, which may be surrounded by
+// whitespace but nothing else.
+func Dearmor(armored []byte) ([]byte, error) {
+	block, rest := pem.Decode(armored)
+	if block == nil || block.Type != fileBlockType || len(bytes.TrimSpace(rest)) != 0 {
+		return nil, ErrBadArmor
+	}
+	return block.Bytes, nil
+}
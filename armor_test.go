@@ -0,0 +1,35 @@
+package minilock
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Armor(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	plaintext := []byte("Some file contents.")
+	encrypted, err := EncryptFileContents("file.txt", plaintext, recipient, recipient, testKey1, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored := Armor(encrypted)
+	if !IsArmored(armored) || IsArmored(encrypted) {
+		t.Error("IsArmored didn't tell armored and binary files apart")
+	}
+	dearmored, err := Dearmor(append([]byte("\n"), armored...))
+	if err != nil || !bytes.Equal(dearmored, encrypted) {
+		t.Fatal("Armor didn't round-trip: ", err)
+	}
+	if msg, err := DecryptMessage(dearmored, recipient); err != nil || !bytes.Equal(msg.Contents, plaintext) {
+		t.Error("Couldn't decrypt dearmored file: ", err)
+	}
+	for name, bad := range map[string][]byte{
+		"binary":        encrypted,
+		"trailing text": append(append([]byte(nil), armored...), "and more"...),
+		"signature":     (&Signature{SignerID: "x", Signature: make([]byte, 64)}).Encode(),
+	} {
+		if _, err = Dearmor(bad); err != ErrBadArmor {
+			t.Error("Expected ErrBadArmor for ", name, ", got: ", err)
+		}
+	}
+}
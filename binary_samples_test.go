@@ -1,5 +1,9 @@
 // Code generated by go-bindata.
 // sources:
+// binary_samples/fminilock.txt
+// binary_samples/fminilock.txt.minilock
+// binary_samples/legacy.txt
+// binary_samples/legacy.txt.minilock
 // binary_samples/mye.go
 // binary_samples/mye.go.minilock
 // DO NOT EDIT!
@@ -28,6 +32,78 @@ type asset struct {
 	info  os.FileInfo
 }
 
+// binary_samplesFminilockTxt reads file data from disk. It returns an error on failure.
+func binary_samplesFminilockTxt() (*asset, error) {
+	path := filepath.Join(rootDir, "binary_samples/fminilock.txt")
+	name := "binary_samples/fminilock.txt"
+	bytes, err := bindataRead(path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		err = fmt.Errorf("Error reading asset info %s at %s: %v", name, path, err)
+	}
+
+	a := &asset{bytes: bytes, info: fi}
+	return a, err
+}
+
+// binary_samplesFminilockTxtMinilock reads file data from disk. It returns an error on failure.
+func binary_samplesFminilockTxtMinilock() (*asset, error) {
+	path := filepath.Join(rootDir, "binary_samples/fminilock.txt.minilock")
+	name := "binary_samples/fminilock.txt.minilock"
+	bytes, err := bindataRead(path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		err = fmt.Errorf("Error reading asset info %s at %s: %v", name, path, err)
+	}
+
+	a := &asset{bytes: bytes, info: fi}
+	return a, err
+}
+
+// binary_samplesLegacyTxt reads file data from disk. It returns an error on failure.
+func binary_samplesLegacyTxt() (*asset, error) {
+	path := filepath.Join(rootDir, "binary_samples/legacy.txt")
+	name := "binary_samples/legacy.txt"
+	bytes, err := bindataRead(path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		err = fmt.Errorf("Error reading asset info %s at %s: %v", name, path, err)
+	}
+
+	a := &asset{bytes: bytes, info: fi}
+	return a, err
+}
+
+// binary_samplesLegacyTxtMinilock reads file data from disk. It returns an error on failure.
+func binary_samplesLegacyTxtMinilock() (*asset, error) {
+	path := filepath.Join(rootDir, "binary_samples/legacy.txt.minilock")
+	name := "binary_samples/legacy.txt.minilock"
+	bytes, err := bindataRead(path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		err = fmt.Errorf("Error reading asset info %s at %s: %v", name, path, err)
+	}
+
+	a := &asset{bytes: bytes, info: fi}
+	return a, err
+}
+
 // binary_samplesMyeGo reads file data from disk. It returns an error on failure.
 func binary_samplesMyeGo() (*asset, error) {
 	path := filepath.Join(rootDir, "binary_samples/mye.go")
@@ -116,8 +192,12 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"binary_samples/mye.go":          binary_samplesMyeGo,
-	"binary_samples/mye.go.minilock": binary_samplesMyeGoMinilock,
+	"binary_samples/fminilock.txt":          binary_samplesFminilockTxt,
+	"binary_samples/fminilock.txt.minilock": binary_samplesFminilockTxtMinilock,
+	"binary_samples/legacy.txt":             binary_samplesLegacyTxt,
+	"binary_samples/legacy.txt.minilock":    binary_samplesLegacyTxtMinilock,
+	"binary_samples/mye.go":                 binary_samplesMyeGo,
+	"binary_samples/mye.go.minilock":        binary_samplesMyeGoMinilock,
 }
 
 // AssetDir returns the file names below a certain
@@ -162,8 +242,12 @@ type bintree struct {
 
 var _bintree = &bintree{nil, map[string]*bintree{
 	"binary_samples": &bintree{nil, map[string]*bintree{
-		"mye.go":          &bintree{binary_samplesMyeGo, map[string]*bintree{}},
-		"mye.go.minilock": &bintree{binary_samplesMyeGoMinilock, map[string]*bintree{}},
+		"fminilock.txt":          &bintree{binary_samplesFminilockTxt, map[string]*bintree{}},
+		"fminilock.txt.minilock": &bintree{binary_samplesFminilockTxtMinilock, map[string]*bintree{}},
+		"legacy.txt":             &bintree{binary_samplesLegacyTxt, map[string]*bintree{}},
+		"legacy.txt.minilock":    &bintree{binary_samplesLegacyTxtMinilock, map[string]*bintree{}},
+		"mye.go":                 &bintree{binary_samplesMyeGo, map[string]*bintree{}},
+		"mye.go.minilock":        &bintree{binary_samplesMyeGoMinilock, map[string]*bintree{}},
 	}},
 }}
 
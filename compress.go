@@ -0,0 +1,113 @@
+package minilock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+)
+
+// CompressionGzip compresses file contents with gzip before encryption.
+const CompressionGzip = "gzip"
+
+// Compressed contents are a series of independently compressed blocks, each a
+// uint32 little-endian length followed by a complete gzip stream of at most
+// compressionBlockSize bytes of contents. A reader can decompress each block
+// as soon as it has been decrypted, holding at most one block's worth of
+// contents, so compressed files can still be streamed.
+const (
+	compressionBlockSize  = 1 << 20
+	compressionLengthSize = 4
+)
+
+func validCompression(algorithm string) bool {
+	return algorithm == "" || algorithm == CompressionGzip
+}
+
+func compress(algorithm string, contents []byte) ([]byte, error) {
+	if algorithm == "" {
+		return contents, nil
+	}
+	if !validCompression(algorithm) {
+		return nil, ErrUnknownCompression
+	}
+	var (
+		out   []byte
+		block bytes.Buffer
+	)
+	w := gzip.NewWriter(&block)
+	for start := 0; start < len(contents); start += compressionBlockSize {
+		end := start + compressionBlockSize
+		if end > len(contents) {
+			end = len(contents)
+		}
+		block.Reset()
+		w.Reset(&block)
+		if _, err := w.Write(contents[start:end]); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		var length [compressionLengthSize]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(block.Len()))
+		out = append(out, length[:]...)
+		out = append(out, block.Bytes()...)
+	}
+	return out, nil
+}
+
+// Decompresses contents block by block, refusing to produce more than maxSize
+// bytes so that a small file can't exhaust memory.
+func decompress(algorithm string, contents []byte, maxSize int64) ([]byte, error) {
+	if algorithm == "" {
+		return contents, nil
+	}
+	if !validCompression(algorithm) {
+		return nil, ErrUnknownCompression
+	}
+	var out []byte
+	for len(contents) > 0 {
+		if len(contents) < compressionLengthSize {
+			return nil, ErrBadCompressedData
+		}
+		length := binary.LittleEndian.Uint32(contents)
+		contents = contents[compressionLengthSize:]
+		if uint64(length) > uint64(len(contents)) {
+			return nil, ErrBadCompressedData
+		}
+		block, err := decompressBlock(contents[:length])
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(out))+int64(len(block)) > maxSize {
+			return nil, ErrDecompressedTooLarge
+		}
+		out = append(out, block...)
+		contents = contents[length:]
+	}
+	return out, nil
+}
+
+// Decompresses one block, which must be a single gzip stream of at most
+// compressionBlockSize bytes.
+func decompressBlock(compressed []byte) ([]byte, error) {
+	// bytes.Reader is read without buffering, so once the stream ends its
+	// remaining length is whatever follows it.
+	br := bytes.NewReader(compressed)
+	r, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, ErrBadCompressedData
+	}
+	r.Multistream(false)
+	block, err := ioutil.ReadAll(io.LimitReader(r, compressionBlockSize+1))
+	if err != nil || len(block) > compressionBlockSize {
+		return nil, ErrBadCompressedData
+	}
+	// Anything after the gzip stream would be silently ignored; refuse it.
+	if br.Len() != 0 {
+		return nil, ErrBadCompressedData
+	}
+	return block, nil
+}
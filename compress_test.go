@@ -0,0 +1,119 @@
+package minilock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+func Test_CompressionRoundTrip(t *testing.T) {
+	plaintext := []byte(strings.Repeat(`{"level":"info","msg":"request served","status":200}`+"\n", 2000))
+	recipient, _ := EphemeralKey()
+	sizes := make(map[string]int)
+	for _, algorithm := range []string{"", CompressionGzip} {
+		encrypted := mustEncrypt(t, plaintext, WithIdentity(testKey1), WithRecipients(recipient), WithFilename("app.log"),
+			WithChunkSize(taber.MinChunkSize), WithCompression(algorithm))
+		sizes[algorithm] = len(encrypted)
+		msg, err := DecryptMessage(encrypted, recipient)
+		if err != nil {
+			t.Fatal("Couldn't decrypt with compression ", algorithm, ": ", err)
+		}
+		if !bytes.Equal(msg.Contents, plaintext) || msg.Compression != algorithm {
+			t.Error("Decrypted message didn't match for compression ", algorithm)
+		}
+		// Older readers must refuse compressed files, not return them raw.
+		wantVersion := HeaderVersionFMiniLock
+		if algorithm != "" {
+			wantVersion = HeaderVersionFramed
+		}
+		if msg.Header.Version != wantVersion {
+			t.Error("Unexpected header version for compression ", algorithm, ": ", msg.Header.Version)
+		}
+
+		// A limit below the plaintext size refuses compressed files only.
+		_, err = DecryptMessage(encrypted, recipient, WithLimits(Limits{MaxDecompressedSize: 1024}))
+		if algorithm == "" && err != nil {
+			t.Error("Uncompressed file refused by decompression limit: ", err)
+		}
+		if algorithm != "" && err != ErrDecompressedTooLarge {
+			t.Error("Expected ErrDecompressedTooLarge, got: ", err)
+		}
+	}
+	if sizes[CompressionGzip]*10 > sizes[""] {
+		t.Error("Compression didn't shrink repetitive contents: ", sizes)
+	}
+	if _, err := NewEncrypter(WithIdentity(testKey1), WithCompression("lzma")); err != ErrUnknownCompression {
+		t.Error("Expected ErrUnknownCompression, got: ", err)
+	}
+}
+
+func Test_CompressionBlocks(t *testing.T) {
+	// Over two blocks' worth, so that the last block is a partial one.
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), (2*compressionBlockSize+1000)/16)
+	compressed, err := compress(CompressionGzip, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := 0
+	for rest := compressed; len(rest) > 0; blocks++ {
+		length := binary.LittleEndian.Uint32(rest)
+		block, err := decompressBlock(rest[compressionLengthSize : compressionLengthSize+length])
+		if err != nil || !bytes.Equal(block, plaintext[blocks*compressionBlockSize:][:len(block)]) {
+			t.Fatal("Block ", blocks, " didn't decompress on its own: ", err)
+		}
+		rest = rest[compressionLengthSize+length:]
+	}
+	if blocks != 3 {
+		t.Error("Expected 3 blocks, got ", blocks)
+	}
+	decompressed, err := decompress(CompressionGzip, compressed, int64(len(plaintext)))
+	if err != nil || !bytes.Equal(decompressed, plaintext) {
+		t.Error("Blocks didn't decompress to the contents: ", err)
+	}
+	if empty, err := compress(CompressionGzip, nil); err != nil || len(empty) != 0 {
+		t.Error("Expected no blocks for empty contents, got: ", empty, err)
+	}
+}
+
+// Prefixes each of blocks with its length.
+func compressedBlocks(blocks ...[]byte) []byte {
+	var out []byte
+	for _, block := range blocks {
+		var length [compressionLengthSize]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(block)))
+		out = append(append(out, length[:]...), block...)
+	}
+	return out
+}
+
+func Test_DecompressHostile(t *testing.T) {
+	valid, err := compress(CompressionGzip, []byte("Some file contents."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := valid[compressionLengthSize:]
+	var oversized bytes.Buffer
+	w := gzip.NewWriter(&oversized)
+	w.Write(make([]byte, compressionBlockSize+1))
+	w.Close()
+	for name, contents := range map[string][]byte{
+		"not gzip":        compressedBlocks([]byte("Some file contents.")),
+		"truncated":       valid[:len(valid)-1],
+		"short length":    append(append([]byte(nil), valid...), 1, 0),
+		"empty block":     compressedBlocks(nil),
+		"trailing data":   compressedBlocks(append(append([]byte(nil), stream...), stream...)),
+		"oversized block": compressedBlocks(oversized.Bytes()),
+	} {
+		if _, err := decompress(CompressionGzip, contents, 1<<30); !errors.Is(err, ErrMalformed) {
+			t.Error("Expected ErrBadCompressedData for ", name, ", got: ", err)
+		}
+	}
+	if _, err := decompress("lzma", valid, 1<<20); err != ErrUnknownCompression {
+		t.Error("Expected ErrUnknownCompression, got: ", err)
+	}
+}
@@ -0,0 +1,57 @@
+package minilock
+
+import (
+	"encoding/base64"
+)
+
+// Returns how many decoy entries to add to a header with entries real ones.
+// Headers are never made larger than DefaultLimits allow, so that they can
+// still be parsed.
+func (settings encryptSettings) decoyCount(entries int) int {
+	target := entries
+	if settings.decoysUpTo > target {
+		target = settings.decoysUpTo
+	}
+	if size := settings.decoyBucket; size > 0 {
+		target = (target + size - 1) / size * size
+	}
+	if target > DefaultLimits.MaxRecipients {
+		target = DefaultLimits.MaxRecipients
+	}
+	if target < entries {
+		return 0
+	}
+	return target - entries
+}
+
+// Adds count decoy entries: random bytes under a random nonce. A real entry is
+// a box, which without the recipient's key is indistinguishable from random
+// bytes, and real entries are padded to one length, so decoys take it too.
+func (hdr *miniLockv1Header) addDecoys(count int) error {
+	length := 0
+	for _, encDI := range hdr.DecryptInfo {
+		length = len(encDI)
+		break
+	}
+	// With no real entries there is nothing for decoys to imitate.
+	if count == 0 || length == 0 {
+		return nil
+	}
+	for added := 0; added < count; {
+		nonce, err := makeFullNonce()
+		if err != nil {
+			return err
+		}
+		nonceS := base64.StdEncoding.EncodeToString(nonce)
+		if _, taken := hdr.DecryptInfo[nonceS]; taken {
+			continue
+		}
+		decoy, err := randBytes(length)
+		if err != nil {
+			return err
+		}
+		hdr.DecryptInfo[nonceS] = decoy
+		added++
+	}
+	return nil
+}
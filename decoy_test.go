@@ -0,0 +1,105 @@
+package minilock
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+func Test_DecoyEntries(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	recipients := make([]*taber.Keys, 0, 3)
+	for i := 0; i < cap(recipients); i++ {
+		recipient, _ := EphemeralKey()
+		recipients = append(recipients, recipient)
+	}
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		entries int
+	}{
+		{"none", nil, 3},
+		{"up to 10", []Option{WithDecoys(10)}, 10},
+		{"up to 2", []Option{WithDecoys(2)}, 3},
+		{"bucket of 8", []Option{WithDecoyBucket(8)}, 8},
+		{"bucket of 2", []Option{WithDecoyBucket(2)}, 4},
+		{"up to 10 in buckets of 4", []Option{WithDecoys(10), WithDecoyBucket(4)}, 12},
+		{"binary header", []Option{WithDecoys(10), WithHeaderVersion(HeaderVersionBinary)}, 10},
+	} {
+		opts := append([]Option{WithIdentity(testKey1), WithRecipients(recipients...), WithFilename("file.txt"), WithoutSelf()}, tc.opts...)
+		encrypted := mustEncrypt(t, plaintext, opts...)
+		hdr, _, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hdr.DecryptInfo) != tc.entries {
+			t.Error("Expected ", tc.entries, " entries with decoys ", tc.name, ", got ", len(hdr.DecryptInfo))
+		}
+		// Real entries and decoys all have one length.
+		lengths := make(map[int]int)
+		for _, encDI := range hdr.DecryptInfo {
+			lengths[len(encDI)]++
+		}
+		if len(lengths) != 1 {
+			t.Error("Entries with decoys ", tc.name, " differ in length: ", lengths)
+		}
+		for _, recipient := range recipients {
+			msg, err := DecryptMessage(encrypted, recipient)
+			if err != nil {
+				t.Fatal("Couldn't decrypt with decoys ", tc.name, ": ", err)
+			}
+			if !bytes.Equal(msg.Contents, plaintext) {
+				t.Error("Decrypted message didn't match with decoys ", tc.name)
+			}
+		}
+		outsider, _ := EphemeralKey()
+		if _, err = DecryptMessage(encrypted, outsider); err != ErrCannotDecrypt {
+			t.Error("Expected ErrCannotDecrypt for an outsider with decoys ", tc.name, ", got: ", err)
+		}
+	}
+	for _, opt := range []Option{WithDecoys(0), WithDecoyBucket(-1), WithDecoys(DefaultLimits.MaxRecipients + 1)} {
+		if _, err := NewEncrypter(WithIdentity(testKey1), opt); err != ErrBadDecoyCount {
+			t.Error("Expected ErrBadDecoyCount, got: ", err)
+		}
+	}
+}
+
+// With decoys, a file to one recipient and a file to three have entries of the
+// same lengths, even when the recipients' IDs differ in length.
+func Test_DecoyLengthProfile(t *testing.T) {
+	recipients := make([]*taber.Keys, 0, 3)
+	for len(recipients) < cap(recipients) {
+		recipient, _ := EphemeralKey()
+		id, _ := recipient.EncodeID()
+		// Make sure at least one ID is shorter than the longest.
+		if len(recipients) == 0 && len(id) == maxIDLength {
+			recipient.Wipe()
+			continue
+		}
+		recipients = append(recipients, recipient)
+	}
+	var profiles [][]int
+	for _, to := range [][]*taber.Keys{recipients[:1], recipients} {
+		encrypted := mustEncrypt(t, []byte("Some file contents."), WithIdentity(testKey1), WithRecipients(to...), WithoutSelf(), WithDecoys(8))
+		hdr, _, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var profile []int
+		for _, encDI := range hdr.DecryptInfo {
+			// The length WithDecoys documents for unframed versions.
+			if len(encDI) != 617 {
+				t.Error("Expected 617-byte entries, got ", len(encDI))
+			}
+			profile = append(profile, len(encDI))
+		}
+		sort.Ints(profile)
+		profiles = append(profiles, profile)
+	}
+	if !reflect.DeepEqual(profiles[0], profiles[1]) {
+		t.Error("Entry lengths reveal the number of recipients: ", profiles[0], " and ", profiles[1])
+	}
+}
@@ -4,10 +4,8 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 
-	"github.com/agl/ed25519"
 	"github.com/cathalgarvey/go-minilock/taber"
 	"github.com/dchest/blake2s"
 )
@@ -21,77 +19,135 @@ func ParseFile(filepath string) (header *miniLockv1Header, ciphertext []byte, er
 	return ParseFileContents(fc)
 }
 
-// ParseFileContents parses a miniLock file and returns header and ciphertext.
+// ParseFileContents parses a miniLock file and returns header and ciphertext,
+// within DefaultLimits.
 func ParseFileContents(contents []byte) (header *miniLockv1Header, ciphertext []byte, err error) {
+	return ParseFileContentsWithLimits(contents, DefaultLimits)
+}
+
+// ParseFileContentsWithLimits parses a miniLock file and returns header and
+// ciphertext, refusing headers larger than limits allow. Headers of unknown
+// versions are refused with a *VersionError.
+func ParseFileContentsWithLimits(contents []byte, limits Limits) (header *miniLockv1Header, ciphertext []byte, err error) {
 	var (
 		headerLengthi32 int32
 		headerLength    int
 		headerBytes     []byte
 	)
+	if len(contents) < len(magicBytes)+4 {
+		return nil, nil, &HeaderError{ErrTruncated}
+	}
 	if string(contents[:8]) != magicBytes {
-		return nil, nil, ErrBadMagicBytes
+		return nil, nil, &HeaderError{ErrBadMagicBytes}
 	}
 	headerLengthi32, err = fromLittleEndian(contents[8:12])
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &HeaderError{err}
 	}
 	headerLength = int(headerLengthi32)
-	if 12+headerLength > len(contents) {
-		return nil, nil, ErrBadLengthPrefix
+	if headerLength < 0 || headerLength > len(contents)-12 {
+		return nil, nil, &HeaderError{ErrBadLengthPrefix}
+	}
+	if headerLength > limits.maxHeaderSize() {
+		return nil, nil, &HeaderError{ErrHeaderTooLarge}
 	}
 	headerBytes = contents[12 : 12+headerLength]
 	ciphertext = contents[12+headerLength:]
-	header = new(miniLockv1Header)
-	err = json.Unmarshal(headerBytes, header)
+	format, err := headerFormatOf(headerBytes)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &HeaderError{err}
+	}
+	header, err = format.decode(headerBytes)
+	if err != nil {
+		return nil, nil, &HeaderError{err}
+	}
+	if len(header.Ephemeral) != 32 {
+		return nil, nil, &HeaderError{taber.ErrBadKeyLength}
+	}
+	if len(header.DecryptInfo) > limits.maxRecipients() {
+		return nil, nil, &HeaderError{ErrTooManyRecipients}
+	}
+	if len(header.PassphraseSlots) > 0 && !format.passphraseSlots {
+		return nil, nil, &HeaderError{ErrPassphraseSlotVersion}
+	}
+	if len(header.PassphraseSlots) > limits.maxPassphraseSlots() {
+		return nil, nil, &HeaderError{ErrTooManyPassphraseSlots}
+	}
+	for _, slot := range header.PassphraseSlots {
+		if err = slot.validate(); err != nil {
+			return nil, nil, &HeaderError{err}
+		}
+		if slot.KDF.Memory() > limits.maxKDFMemory() {
+			return nil, nil, &HeaderError{ErrPassphraseSlotTooCostly}
+		}
 	}
 	return header, ciphertext, nil
 }
 
 // DecryptFileContents parses header and ciphertext from a file, decrypts the
 // header with recipientKey, and uses details therein to decrypt the enclosed file.
+// It is DecryptMessage with the result unpacked.
 // Returns sender, filename, file contents if successful, or an error if not;
 // Check the error to see if it's benign (cannot decrypt with given key) or bad.
+// For anonymous messages senderIdentityID is AnonymousSender and replyToID is
+// empty: nothing about the sender is known or authenticated.
 func DecryptFileContents(fileContents []byte, recipientKey *taber.Keys) (senderIdentityID, senderID, replyToID, filename string, contents []byte, err error) {
-	var (
-		header     *miniLockv1Header
-		ciphertext []byte
-	)
-	header, ciphertext, err = ParseFileContents(fileContents)
+	msg, err := DecryptMessage(fileContents, recipientKey)
 	if err != nil {
 		return "", "", "", "", nil, err
 	}
-	return header.DecryptContents(ciphertext, recipientKey)
+	return msg.SenderIdentityID, msg.SenderID, msg.ReplyToID, msg.Filename, msg.Contents, nil
 }
 
-// DecryptFileContentsWithStrings is the highest-level API for decryption.
-// It uses the recipient's email and passphrase to generate their key, attempts
+// DecryptFileContentsWithStrings is DecryptMessageWithStrings with the result
+// unpacked. It uses the recipient's email and passphrase to generate their key, attempts
 // decryption, and wipes keys when finished.
 func DecryptFileContentsWithStrings(fileContents []byte, recipientEmail, recipientPassphrase string) (senderIdentityID, senderID, replyToID, filename string, contents []byte, err error) {
-	var recipientKey *taber.Keys
-	recipientKey, err = taber.FromEmailAndPassphrase(recipientEmail, recipientPassphrase)
+	msg, err := DecryptMessageWithStrings(fileContents, recipientEmail, recipientPassphrase)
 	if err != nil {
-		return
+		return "", "", "", "", nil, err
 	}
-	defer recipientKey.Wipe()
-	return DecryptFileContents(fileContents, recipientKey)
+	return msg.SenderIdentityID, msg.SenderID, msg.ReplyToID, msg.Filename, msg.Contents, nil
 }
 
 // DecryptFile - Given a ciphertext, walk it into length prefixed chunks and decrypt/reassemble
 // each chunk, then validate the hash of the file against the hash given in FileInfo.
 // The result is a validated, decrypted filename and file contents byte-slice.
+// Framed payloads are unframed, and their metadata discarded; compressed
+// contents are decompressed within DefaultLimits.
 func (fi *FileInfo) DecryptFile(ciphertext []byte) (filename string, contents []byte, err error) {
+	filename, contents, _, err = fi.decryptFile(ciphertext, DefaultLimits)
+	return filename, contents, err
+}
+
+// As above, also returning the frame header of framed payloads, or an empty
+// one.
+func (fi *FileInfo) decryptFile(ciphertext []byte, limits Limits) (filename string, contents []byte, header *frameHeader, err error) {
 	var (
 		hash [32]byte
 		DI   taber.DecryptInfo
 	)
 	hash = blake2s.Sum256(ciphertext)
 	if !bytes.Equal(fi.FileHash, hash[:]) {
-		return "", nil, ErrCTHashMismatch
+		return "", nil, nil, ErrCTHashMismatch
+	}
+	DI = taber.DecryptInfo{Key: fi.FileKey, BaseNonce: fi.FileNonce, ChunkSize: fi.ChunkSize}
+	filename, contents, err = DI.Decrypt(ciphertext)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	header = new(frameHeader)
+	if fi.Framed {
+		header, contents, err = unframe(contents)
+		if err != nil {
+			return "", nil, nil, err
+		}
 	}
-	DI = taber.DecryptInfo{Key: fi.FileKey, BaseNonce: fi.FileNonce}
-	return DI.Decrypt(ciphertext)
+	contents, err = decompress(header.Compression, contents, limits.maxDecompressedSize())
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return filename, contents, header, nil
 }
 
 // DecryptDecryptInfo is used to extract a decryptInfo object by attempting decryption
@@ -99,34 +155,49 @@ func (fi *FileInfo) DecryptFile(ciphertext []byte) (filename string, contents []
 // until one works or none work, as miniLock deliberately provides no indication of
 // intended recipients.
 func DecryptDecryptInfo(diEnc, nonce []byte, ephemPubkey, recipientKey *taber.Keys) (*DecryptInfoEntry, error) {
+	return decryptDecryptInfo(diEnc, nonce, ephemPubkey, recipientKey, false)
+}
+
+// As above, but accepting legacy entries without an identity if allowLegacy.
+func decryptDecryptInfo(diEnc, nonce []byte, ephemPubkey, recipientKey *taber.Keys, allowLegacy bool) (*DecryptInfoEntry, error) {
 	plain, err := recipientKey.Decrypt(diEnc, nonce, ephemPubkey)
 	if err != nil {
 		return nil, ErrCannotDecrypt
 	}
+	return parseDecryptInfo(plain, allowLegacy)
+}
+
+// Parses and verifies a decrypted decryptInfo entry.
+func parseDecryptInfo(plain []byte, allowLegacy bool) (*DecryptInfoEntry, error) {
 	di := new(DecryptInfoEntry)
-	err = json.Unmarshal(plain, di)
+	err := json.Unmarshal(plain, di)
 	if err != nil {
-		return nil, err
+		return nil, &HeaderError{err}
 	}
 
+	if di.Anonymous {
+		if di.SenderIdentityID != "" || di.Verification != "" || di.ReplyToID != "" {
+			return nil, &HeaderError{ErrBadAnonymousEntry}
+		}
+		return di, nil
+	}
+	if di.isLegacy() {
+		if !allowLegacy {
+			return nil, ErrLegacyFile
+		}
+		return di, nil
+	}
 	// Verify signature
-	contentToVerify := di.contentToVerify()
 	k, err := IdentityFromID(di.SenderIdentityID)
 	if err != nil {
-		return nil, err
+		return nil, &HeaderError{err}
 	}
-	var pk [ed25519.PublicKeySize]byte
-	copy(pk[:], k.Public)
-
 	rawsig, err := base64.StdEncoding.DecodeString(di.Verification)
 	if err != nil {
-		return nil, err
+		return nil, &HeaderError{err}
 	}
-	var sig [ed25519.SignatureSize]byte
-	copy(sig[:], rawsig)
-	ok := ed25519.Verify(&pk, contentToVerify, &sig)
-	if !ok {
-		return nil, fmt.Errorf("Invalid signature from sender identity")
+	if !k.Verify(di.contentToVerify(), rawsig) {
+		return nil, &SignatureError{SignerID: di.SenderIdentityID}
 	}
 	return di, nil
 }
@@ -134,19 +205,20 @@ func DecryptDecryptInfo(diEnc, nonce []byte, ephemPubkey, recipientKey *taber.Ke
 // ExtractFileInfo pulls out the fileInfo object from the decryptInfo object,
 // authenticating encryption from the sender.
 func (di *DecryptInfoEntry) ExtractFileInfo(nonce []byte, recipientKey *taber.Keys) (*FileInfo, error) {
-	// Return on failure: minilockutils.DecryptionError
 	senderPubkey, err := di.SenderPubkey()
 	if err != nil {
-		return nil, err
+		return nil, &HeaderError{err}
 	}
 	plain, err := recipientKey.Decrypt(di.FileInfoEnc, nonce, senderPubkey)
 	if err != nil {
 		return nil, ErrCannotDecrypt
 	}
+	// The encoded fileInfo contains the file key.
+	defer taber.WipeBytes(plain)
 	fi := new(FileInfo)
 	err = json.Unmarshal(plain, fi)
 	if err != nil {
-		return nil, err
+		return nil, &HeaderError{err}
 	}
 	return fi, nil
 }
@@ -154,40 +226,70 @@ func (di *DecryptInfoEntry) ExtractFileInfo(nonce []byte, recipientKey *taber.Ke
 // ExtractDecryptInfo iterates through the header using recipientKey and
 // attempts to decrypt any DecryptInfoEntry using the provided ephemeral key.
 // If unsuccessful after iterating through all decryptInfo objects, returns ErrCannotDecrypt.
+// Every entry is tried, even after one decrypts, so that the time taken
+// doesn't reveal which entry, real or decoy, was for recipientKey.
 func (hdr *miniLockv1Header) ExtractDecryptInfo(recipientKey *taber.Keys) (nonce []byte, DI *DecryptInfoEntry, err error) {
-	var (
-		ephemKey *taber.Keys
-		encDI    []byte
-		nonceS   string
-	)
-	ephemKey = new(taber.Keys)
-	ephemKey.Public = hdr.Ephemeral
-	if err != nil {
-		return nil, nil, err
-	}
-	// Look for a DI we can decrypt with recipientKey
-	// TODO: Make this concurrent!
-	for nonceS, encDI = range hdr.DecryptInfo {
-		nonce, err := base64.StdEncoding.DecodeString(nonceS)
+	return hdr.extractDecryptInfo(recipientKey, false)
+}
+
+// As above, but accepting legacy entries without an identity if allowLegacy.
+func (hdr *miniLockv1Header) extractDecryptInfo(recipientKey *taber.Keys, allowLegacy bool) (nonce []byte, DI *DecryptInfoEntry, err error) {
+	nonce, DI, _, err = hdr.extractDecryptInfoWithKeys([]*taber.Keys{recipientKey}, allowLegacy)
+	return nonce, DI, err
+}
+
+// As above, trying every entry with every key, and returning the first key
+// that decrypts one. The key shared with the ephemeral key is computed once
+// per recipient key, so each further entry costs only a symmetric decryption.
+func (hdr *miniLockv1Header) extractDecryptInfoWithKeys(keys []*taber.Keys, allowLegacy bool) (nonce []byte, DI *DecryptInfoEntry, recipientKey *taber.Keys, err error) {
+	var plain []byte
+	ephemKey := &taber.Keys{Public: hdr.Ephemeral}
+	nonces := make([][]byte, 0, len(hdr.DecryptInfo))
+	entries := make([][]byte, 0, len(hdr.DecryptInfo))
+	for nonceS, encDI := range hdr.DecryptInfo {
+		entryNonce, err := base64.StdEncoding.DecodeString(nonceS)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, &HeaderError{err}
 		}
-		DI, err = DecryptDecryptInfo(encDI, nonce, ephemKey, recipientKey)
-		if err == ErrCannotDecrypt {
-			continue
-		} else if err != nil {
-			return nil, nil, err
+		if len(entryNonce) != 24 {
+			return nil, nil, nil, &HeaderError{taber.ErrBadNonceLength}
 		}
-		recipID, err := recipientKey.EncodeID()
+		nonces = append(nonces, entryNonce)
+		entries = append(entries, encDI)
+	}
+	// Look for a DI we can decrypt with one of keys
+	for _, key := range keys {
+		// Keys without a private part can't decrypt any entry.
+		shared, err := key.Precompute(ephemKey)
 		if err != nil {
-			return nil, nil, err
+			continue
 		}
-		if DI.RecipientID != recipID {
-			return nil, nil, ErrBadRecipient
+		for i, encDI := range entries {
+			entryPlain, err := shared.Open(encDI, nonces[i])
+			if err != nil || plain != nil {
+				continue
+			}
+			nonce, plain, recipientKey = nonces[i], entryPlain, key
 		}
-		return nonce, DI, nil
+		shared.Wipe()
+	}
+	if plain == nil {
+		return nil, nil, nil, ErrCannotDecrypt
 	}
-	return nil, nil, ErrCannotDecrypt
+	DI, err = parseDecryptInfo(plain, allowLegacy && hdr.format().legacyEntries)
+	if err == ErrLegacyFile && !hdr.format().legacyEntries {
+		return nil, nil, nil, &HeaderError{ErrUnsignedEntry}
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+	recipID, err := recipientKey.EncodeID()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if DI.RecipientID != recipID {
+		return nil, nil, nil, &HeaderError{ErrBadRecipient}
+	}
+	return nonce, DI, recipientKey, nil
 }
 
 // ExtractFileInfo tries to pull out a fileInfo all-at-once using a recipientKey.
@@ -202,21 +304,17 @@ func (hdr *miniLockv1Header) ExtractFileInfo(recipientKey *taber.Keys) (fileinfo
 	if err != nil {
 		return nil, "", "", "", err
 	}
-	return fileinfo, DI.SenderIdentityID, DI.SenderID, DI.ReplyToID, nil
+	return fileinfo, DI.SenderIdentity(), DI.SenderID, DI.ReplyToID, nil
 }
 
 // DecryptContents uses a miniLock file's header to attempt decryption of its ciphertext
 // all-at-once, enclosing the lower-level operations entirely. It can fail for all
 // the usual reasons including that the file simply isn't encrypted to this recipient.
+// It is DecryptMessage with the result unpacked.
 func (hdr *miniLockv1Header) DecryptContents(ciphertext []byte, recipientKey *taber.Keys) (senderIdentityID, senderID, replyToID, filename string, contents []byte, err error) {
-	var FI *FileInfo
-	FI, senderIdentityID, senderID, replyToID, err = hdr.ExtractFileInfo(recipientKey)
-	if err != nil {
-		return "", "", "", "", nil, err
-	}
-	filename, contents, err = FI.DecryptFile(ciphertext)
+	msg, err := hdr.DecryptMessage(ciphertext, recipientKey)
 	if err != nil {
 		return "", "", "", "", nil, err
 	}
-	return senderIdentityID, senderID, replyToID, filename, contents, nil
+	return msg.SenderIdentityID, msg.SenderID, msg.ReplyToID, msg.Filename, msg.Contents, nil
 }
@@ -2,6 +2,7 @@ package minilock
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -63,3 +64,38 @@ func Test_ParseMinilockFile(t *testing.T) {
 		t.Error("Plaintext did not match expected plaintext.")
 	}
 }
+
+func Test_ParseHostileInput(t *testing.T) {
+	for _, tc := range []struct {
+		input []byte
+		err   error
+	}{
+		{[]byte("mini"), ErrTruncated},
+		{[]byte("miniLock\xff\xff\xff\xff{}"), ErrBadLengthPrefix},
+		{[]byte("miniLock\x10\x00\x00\x00{}"), ErrBadLengthPrefix},
+	} {
+		_, _, err := ParseFileContents(tc.input)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Expected %v for %q, got: %v", tc.err, tc.input, err)
+		}
+	}
+	testcase, err := Asset("binary_samples/mye.go.minilock")
+	if err != nil {
+		t.Fatal("Couldn't load test binary asset.")
+	}
+	_, _, err = ParseFileContentsWithLimits(testcase, Limits{MaxHeaderSize: 100, MaxRecipients: 10})
+	if !errors.Is(err, ErrHeaderTooLarge) {
+		t.Error("Expected ErrHeaderTooLarge, got: ", err)
+	}
+	recipient1, _ := EphemeralKey()
+	recipient2, _ := EphemeralKey()
+	encrypted := mustEncrypt(t, []byte("contents"), WithIdentity(testKey1), WithRecipients(recipient1, recipient2))
+	_, _, err = ParseFileContentsWithLimits(encrypted, Limits{MaxRecipients: 1})
+	if !errors.Is(err, ErrTooManyRecipients) {
+		t.Error("Expected ErrTooManyRecipients, got: ", err)
+	}
+	// Zero limits mean the defaults rather than nothing at all.
+	if _, _, err = ParseFileContentsWithLimits(encrypted, Limits{}); err != nil {
+		t.Error("Expected zero Limits to apply the defaults, got: ", err)
+	}
+}
@@ -11,26 +11,55 @@ import (
 // EncryptFileToFileInfo symmetrically encrypts a file or plaintext and returns
 // the fileInfo object to decrypt it and the raw ciphertext. This operation is
 // technically independent of miniLock and could be used for other crypto-schemes
-// as a handy way to encrypt files symmetrically.
+// as a handy way to encrypt files symmetrically. Empty files may be encrypted.
 func EncryptFileToFileInfo(filename string, filecontents []byte) (FI *FileInfo, ciphertext []byte, err error) {
+	return encryptToFileInfo(encryptSettings{}, filename, filecontents)
+}
+
+func encryptToFileInfo(settings encryptSettings, filename string, filecontents []byte) (FI *FileInfo, ciphertext []byte, err error) {
 	var (
 		DI *taber.DecryptInfo
 	)
-	DI, err = taber.NewDecryptInfo()
+	DI, err = taber.NewDecryptInfoFrom(randReader)
 	if err != nil {
 		return nil, nil, err
 	}
-	return encryptFileToFileInfo(DI, filename, filecontents)
+	return encryptFileToFileInfo(DI, settings, filename, filecontents)
 }
 
 // Separated from the above for testing purposes; deterministic ciphertext.
-func encryptFileToFileInfo(DI *taber.DecryptInfo, filename string, filecontents []byte) (FI *FileInfo, ciphertext []byte, err error) {
+func encryptFileToFileInfo(DI *taber.DecryptInfo, settings encryptSettings, filename string, filecontents []byte) (FI *FileInfo, ciphertext []byte, err error) {
 	var hash [32]byte
-	if filecontents == nil || len(filecontents) == 0 {
-		return nil, nil, ErrNilPlaintext
+	// The default is left unrecorded, so older readers can decrypt the file.
+	if settings.chunkSize != taber.ConstChunkSize {
+		DI.ChunkSize = settings.chunkSize
 	}
-	ciphertext, err = DI.Encrypt(filename, filecontents)
+	compressed, err := compress(settings.compression, filecontents)
 	if err != nil {
+		DI.Wipe()
+		return nil, nil, err
+	}
+	payload := compressed
+	// resolveVersion gives files that need a frame a framed version.
+	framed := headerFormats[settings.headerVersion].framed
+	if framed {
+		header := &frameHeader{Compression: settings.compression, Padding: settings.padding}
+		if settings.metadata != nil {
+			header.Metadata = settings.metadata.withSize(len(filecontents))
+		}
+		payload, err = frame(header, compressed)
+		if err != nil {
+			DI.Wipe()
+			return nil, nil, err
+		}
+	}
+	if settings.noFilename {
+		ciphertext, err = DI.EncryptWithoutName(payload)
+	} else {
+		ciphertext, err = DI.Encrypt(filename, payload)
+	}
+	if err != nil {
+		DI.Wipe()
 		return nil, nil, err
 	}
 	hash = blake2s.Sum256(ciphertext)
@@ -38,6 +67,8 @@ func encryptFileToFileInfo(DI *taber.DecryptInfo, filename string, filecontents
 	FI.FileKey = DI.Key
 	FI.FileNonce = DI.BaseNonce
 	FI.FileHash = hash[:]
+	FI.ChunkSize = DI.ChunkSize
+	FI.Framed = framed
 	return FI, ciphertext, nil
 }
 
@@ -48,6 +79,8 @@ func NewDecryptInfoEntry(nonce []byte, fileinfo *FileInfo, senderKey, recipientK
 	if err != nil {
 		return nil, err
 	}
+	// The encoded fileInfo contains the file key.
+	defer taber.WipeBytes(encodedFi)
 	cipherFi, err := senderKey.Encrypt(encodedFi, nonce, recipientKey)
 	if err != nil {
 		return nil, err
@@ -78,18 +111,52 @@ func NewDecryptInfoEntry(nonce []byte, fileinfo *FileInfo, senderKey, recipientK
 	}
 	contentToVerify := di.contentToVerify()
 	verification := senderIdentity.Sign(contentToVerify)
+	if verification == nil {
+		return nil, ErrCannotSign
+	}
 	di.Verification = base64.StdEncoding.EncodeToString(verification)
 
 	return di, nil
 }
 
+// NewAnonymousDecryptInfoEntry creates an anonymous decryptInfo entry for the
+// given fileInfo to the intended recipientKey. senderKey should be a random key
+// used for this message only; there is no identity, signature or reply-to.
+func NewAnonymousDecryptInfoEntry(nonce []byte, fileinfo *FileInfo, senderKey, recipientKey *taber.Keys) (*DecryptInfoEntry, error) {
+	encodedFi, err := json.Marshal(fileinfo)
+	if err != nil {
+		return nil, err
+	}
+	defer taber.WipeBytes(encodedFi)
+	cipherFi, err := senderKey.Encrypt(encodedFi, nonce, recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	senderID, err := senderKey.EncodeID()
+	if err != nil {
+		return nil, err
+	}
+	recipientID, err := recipientKey.EncodeID()
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptInfoEntry{
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		FileInfoEnc: cipherFi,
+		Anonymous:   true,
+	}, nil
+}
+
 // EncryptDecryptInfo encrypts a decryptInfo struct using the ephemeral pubkey
-// and the same nonce as the enclosed fileInfo.
+// and the same nonce as the enclosed fileInfo. The encoded struct is padded so
+// that its length doesn't depend on the lengths of the IDs in it.
 func EncryptDecryptInfo(di *DecryptInfoEntry, nonce []byte, ephemKey, recipientKey *taber.Keys) ([]byte, error) {
 	plain, err := json.Marshal(di)
 	if err != nil {
 		return nil, err
 	}
+	plain = padDecryptInfo(plain, di)
 	// NaClKeypair.Encrypt(plaintext, nonce []byte, to *NaClKeypair) (ciphertext []byte, err error)
 	diEnc, err := ephemKey.Encrypt(plain, nonce, recipientKey)
 	if err != nil {
@@ -98,14 +165,40 @@ func EncryptDecryptInfo(di *DecryptInfoEntry, nonce []byte, ephemKey, recipientK
 	return diEnc, nil
 }
 
+// The length of the longest ID: 33 bytes, a key and its checksum, in base58.
+const maxIDLength = 46
+
+// Pads an encoded decryptInfo struct with spaces, which JSON ignores, to the
+// length it would have if every ID in it were maxIDLength long. Base58 IDs
+// vary in length, so without this the entries of one file would differ in
+// length by recipient, and decoys couldn't match them all.
+func padDecryptInfo(plain []byte, di *DecryptInfoEntry) []byte {
+	padding := 0
+	for _, id := range []string{di.SenderID, di.RecipientID, di.ReplyToID, di.SenderIdentityID} {
+		if id != "" && len(id) < maxIDLength {
+			padding += maxIDLength - len(id)
+		}
+	}
+	for i := 0; i < padding; i++ {
+		plain = append(plain, ' ')
+	}
+	return plain
+}
+
 func (hdr *miniLockv1Header) addFileInfo(fileInfo *FileInfo, ephem, sender, replyTo *taber.Keys, identity *IdentityKeys, recipients ...*taber.Keys) error {
 	for _, recipientKey := range recipients {
 		nonce, rgerr := makeFullNonce()
 		if rgerr != nil {
 			return rgerr
 		}
-		// NewDecryptInfoEntry(nonce []byte, fileinfo *FileInfo, senderKey, recipientKey *taber.Keys) (*DecryptInfoEntry, error) {
-		DI, rgerr := NewDecryptInfoEntry(nonce, fileInfo, sender, recipientKey, replyTo, identity)
+		// A nil identity means an anonymous message; the exported entry points
+		// make sure that only happens when asked for.
+		var DI *DecryptInfoEntry
+		if identity == nil {
+			DI, rgerr = NewAnonymousDecryptInfoEntry(nonce, fileInfo, sender, recipientKey)
+		} else {
+			DI, rgerr = NewDecryptInfoEntry(nonce, fileInfo, sender, recipientKey, replyTo, identity)
+		}
 		if rgerr != nil {
 			return rgerr
 		}
@@ -120,27 +213,22 @@ func (hdr *miniLockv1Header) addFileInfo(fileInfo *FileInfo, ephem, sender, repl
 }
 
 // EncryptFileContentsWithStrings is an entry point that largely defines "normal"
-// miniLock behaviour. If sendToSender is true, then the sender's ID is added to recipients.
+// miniLock behaviour. If sendToSender is true, then the box key derived from the
+// sender's email and passphrase is added to recipients, so the sender can decrypt
+// the file again with the same email and passphrase.
 func EncryptFileContentsWithStrings(filename string, fileContents []byte, senderEmail, senderPassphrase string, sendToSender bool, recipientIDs ...string) (miniLockContents []byte, replyTo *taber.Keys, err error) {
 	var (
-		senderKey, thisRecipient *taber.Keys
-		recipientKeyList         []*taber.Keys
-		thisID                   string
-		identity                 *IdentityKeys
+		senderKey, selfKey, thisRecipient *taber.Keys
+		recipientKeyList                  []*taber.Keys
+		thisID                            string
+		identity                          *IdentityKeys
 	)
 	senderKey, err = EphemeralKey()
 	if err != nil {
 		return nil, nil, err
 	}
 	defer senderKey.Wipe()
-	if sendToSender {
-		thisID, err = senderKey.EncodeID()
-		if err != nil {
-			return nil, nil, err
-		}
-		recipientIDs = append(recipientIDs, thisID)
-	}
-	recipientKeyList = make([]*taber.Keys, 0, len(recipientIDs))
+	recipientKeyList = make([]*taber.Keys, 0, len(recipientIDs)+1)
 	// TODO: Randomise iteration here?
 	for _, thisID = range recipientIDs {
 		thisRecipient, err = taber.FromID(thisID)
@@ -155,10 +243,15 @@ func EncryptFileContentsWithStrings(filename string, fileContents []byte, sender
 		return nil, nil, err
 	}
 
-	identity, err = IdentityFromEmailAndPassphrase(senderEmail, senderPassphrase)
+	selfKey, identity, err = GenerateKeys(senderEmail, senderPassphrase)
 	if err != nil {
 		return nil, nil, err
 	}
+	defer selfKey.Wipe()
+	defer identity.Wipe()
+	if sendToSender {
+		recipientKeyList = append(recipientKeyList, selfKey.PublicOnly())
+	}
 	miniLockContents, err = EncryptFileContents(filename, fileContents, senderKey, replyTo, identity, recipientKeyList...)
 	if err != nil {
 		return nil, nil, err
@@ -171,25 +264,97 @@ func EncryptFileContentsWithStrings(filename string, fileContents []byte, sender
 // sender key to prepared recipient keys. EncryptFileContentsWithStrings is much
 // easier to use for most applications.
 func EncryptFileContents(filename string, fileContents []byte, sender, replyTo *taber.Keys, identity *IdentityKeys, recipients ...*taber.Keys) (miniLockContents []byte, err error) {
+	if identity == nil {
+		return nil, ErrNoSenderIdentity
+	}
+	return encryptFileContents(encryptSettings{}, filename, fileContents, sender, replyTo, identity, recipients...)
+}
+
+// EncryptFileContentsAnonymously encrypts byte slices to prepared recipient keys
+// without identifying the sender, even to the recipients: the message carries no
+// identity, signature or reply-to, and is sent from a random key that is wiped
+// afterwards. Recipients are told the message is anonymous, and so unauthenticated.
+func EncryptFileContentsAnonymously(filename string, fileContents []byte, recipients ...*taber.Keys) (miniLockContents []byte, err error) {
+	return encryptFileContentsAnonymously(encryptSettings{}, filename, fileContents, recipients...)
+}
+
+func encryptFileContentsAnonymously(settings encryptSettings, filename string, fileContents []byte, recipients ...*taber.Keys) (miniLockContents []byte, err error) {
+	sender, err := EphemeralKey()
+	if err != nil {
+		return nil, err
+	}
+	defer sender.Wipe()
+	return encryptFileContents(settings, filename, fileContents, sender, nil, nil, recipients...)
+}
+
+// EncryptFileContentsAnonymouslyWithStrings is EncryptFileContentsAnonymously
+// for recipients given as miniLock IDs.
+func EncryptFileContentsAnonymouslyWithStrings(filename string, fileContents []byte, recipientIDs ...string) (miniLockContents []byte, err error) {
+	recipientKeyList := make([]*taber.Keys, 0, len(recipientIDs))
+	for _, thisID := range recipientIDs {
+		thisRecipient, err := taber.FromID(thisID)
+		if err != nil {
+			return nil, err
+		}
+		recipientKeyList = append(recipientKeyList, thisRecipient)
+	}
+	return EncryptFileContentsAnonymously(filename, fileContents, recipientKeyList...)
+}
+
+// Settings for the parts of a file other than its keys; the zero value is what
+// the EncryptFileContents family uses.
+type encryptSettings struct {
+	headerVersion int
+	// noFilename encrypts without a name block, for short messages.
+	noFilename bool
+	// chunkSize is the length of chunks; zero means taber.ConstChunkSize.
+	chunkSize int
+	// metadata is stored in the payload frame if not nil.
+	metadata *Metadata
+	// compression is applied to the contents before framing if not empty.
+	compression string
+	// padding is the policy the payload frame is padded under.
+	padding string
+	// Decoy entries are added so the header holds at least decoysUpTo
+	// entries, rounded up to a multiple of decoyBucket.
+	decoysUpTo, decoyBucket int
+	// passphraseSlots are written to the header; their keys are among the
+	// recipients.
+	passphraseSlots []passphraseSlot
+}
+
+// A nil identity (and replyTo) produces an anonymous message.
+func encryptFileContents(settings encryptSettings, filename string, fileContents []byte, sender, replyTo *taber.Keys, identity *IdentityKeys, recipients ...*taber.Keys) (miniLockContents []byte, err error) {
 	var (
 		hdr        *miniLockv1Header
 		ephem      *taber.Keys
 		ciphertext []byte
 		fileInfo   *FileInfo
 	)
-	hdr, ephem, err = prepareNewHeader()
+	settings.headerVersion, err = settings.resolveVersion()
+	if err != nil {
+		return nil, err
+	}
+	hdr, ephem, err = prepareNewHeader(settings.headerVersion)
 	if err != nil {
 		return nil, err
 	}
-	fileInfo, ciphertext, err = EncryptFileToFileInfo(filename, fileContents)
+	defer ephem.Wipe()
+	hdr.PassphraseSlots = settings.passphraseSlots
+	fileInfo, ciphertext, err = encryptToFileInfo(settings, filename, fileContents)
 	if err != nil {
 		return nil, err
 	}
+	defer fileInfo.Wipe()
 	err = hdr.addFileInfo(fileInfo, ephem, sender, replyTo, identity, recipients...)
 	if err != nil {
 		return nil, err
 	}
-	miniLockContents = make([]byte, 0, 8+4+hdr.encodedLength()+len(ciphertext))
+	err = hdr.addDecoys(settings.decoyCount(len(hdr.DecryptInfo)))
+	if err != nil {
+		return nil, err
+	}
+	miniLockContents = make([]byte, 0, len(magicBytes)+4+hdr.encodedLength()+len(ciphertext))
 	miniLockContents, err = hdr.stuffSelf(miniLockContents)
 	if err != nil {
 		return nil, err
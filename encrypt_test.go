@@ -3,6 +3,8 @@ package minilock
 import (
 	"bytes"
 	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
 )
 
 func Test_RoundTripMinilock(t *testing.T) {
@@ -72,6 +74,7 @@ func Test_RoundTripMinilock(t *testing.T) {
 
 func Test_EncryptEmptyFile(t *testing.T) {
 	// With thanks to github.com/sahib for discovering and reporting this bug!
+	// Empty files used to be refused, and are now encrypted as one empty chunk.
 	keys, err := EphemeralKey()
 	if err != nil {
 		t.Error(err.Error())
@@ -81,9 +84,148 @@ func Test_EncryptEmptyFile(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	_, err = EncryptFileContents("/tmp/dummy", []byte{}, keys, keys, identity, keys)
-	if err != ErrNilPlaintext {
-		t.Error("Got wrong error for empty plaintext:", err.Error())
+	for _, empty := range [][]byte{nil, {}} {
+		genCrypted, err := EncryptFileContents("/tmp/dummy", empty, keys, keys, identity, keys)
+		if err != nil {
+			t.Fatal("Couldn't encrypt empty file: ", err)
+		}
+		msg, err := DecryptMessage(genCrypted, keys)
+		if err != nil {
+			t.Fatal("Couldn't decrypt empty file: ", err)
+		}
+		if msg.Filename != "/tmp/dummy" || len(msg.Contents) != 0 {
+			t.Error("Empty file didn't round-trip: ", msg.Filename, msg.Contents)
+		}
+	}
+}
+
+func Test_EncryptWithoutFilename(t *testing.T) {
+	recipient, err := EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEncrypter(WithIdentity(testKey1), WithRecipients(recipient), WithoutSelf(), WithoutFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"", "Your build finished."} {
+		genCrypted, replyTo, err := e.Encrypt([]byte(text))
+		if err != nil {
+			t.Fatal("Couldn't encrypt message: ", err)
+		}
+		replyTo.Wipe()
+		named, err := EncryptFileContents("", []byte(text), recipient, recipient, testKey1, recipient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the name block differs in size; headers vary with the lengths of IDs.
+		_, unnamedCiphertext, err := ParseFileContents(genCrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, namedCiphertext, err := ParseFileContents(named)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(namedCiphertext)-len(unnamedCiphertext) != taber.ConstFilenameBlockLength-20 {
+			t.Error("Unnamed message ciphertext was ", len(unnamedCiphertext), " bytes, named file ", len(namedCiphertext))
+		}
+		msg, err := DecryptMessage(genCrypted, recipient)
+		if err != nil {
+			t.Fatal("Couldn't decrypt message: ", err)
+		}
+		if msg.Filename != "" || string(msg.Contents) != text {
+			t.Error("Message didn't round-trip: ", msg.Filename, string(msg.Contents))
+		}
+	}
+	if _, err = NewEncrypter(WithIdentity(testKey1), WithFilename("file.txt"), WithoutFilename()); err != ErrFilenameOptions {
+		t.Error("Expected ErrFilenameOptions, got: ", err)
+	}
+}
+
+func Test_AnonymousRoundTrip(t *testing.T) {
+	recipient, err := EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("Nobody knows who sent this.")
+	if _, err = EncryptFileContents("tip.txt", plaintext, recipient, recipient, nil, recipient.PublicOnly()); err != ErrNoSenderIdentity {
+		t.Error("Expected ErrNoSenderIdentity without an identity, got: ", err)
+	}
+	recipientID, err := recipient.EncodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genCrypted, err := EncryptFileContentsAnonymouslyWithStrings("tip.txt", plaintext, recipientID)
+	if err != nil {
+		t.Fatal("Couldn't encrypt anonymously: ", err.Error())
+	}
+	senderIdentityID, _, replyToID, filename, contents, err := DecryptFileContents(genCrypted, recipient)
+	if err != nil {
+		t.Fatal("Failed to decrypt anonymous message: " + err.Error())
+	}
+	if senderIdentityID != AnonymousSender {
+		t.Error("Anonymous message wasn't flagged as such, sender identity was: ", senderIdentityID)
+	}
+	if replyToID != "" {
+		t.Error("Anonymous message had a reply-to ID: ", replyToID)
+	}
+	if filename != "tip.txt" || !bytes.Equal(contents, plaintext) {
+		t.Error("Anonymous message didn't round-trip: ", filename, string(contents))
+	}
+}
+
+func Test_EncryptToSelf(t *testing.T) {
+	email, passphrase := "cathalgarvey@some.where", "this is a password that totally works for minilock purposes"
+	plaintext := []byte("A copy for me.")
+	recipient, err := EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipientID, err := recipient.EncodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, err := GenerateKey(email, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer self.Wipe()
+	for _, sendToSender := range []bool{true, false} {
+		genCrypted, replyTo, err := EncryptFileContentsWithStrings("self.txt", plaintext, email, passphrase, sendToSender, recipientID)
+		if err != nil {
+			t.Fatal("Couldn't encrypt: ", err)
+		}
+		replyTo.Wipe()
+		msg, err := DecryptMessage(genCrypted, self)
+		if !sendToSender {
+			if err != ErrCannotDecrypt {
+				t.Error("Sender could decrypt a file not encrypted to self: ", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Sender couldn't decrypt their own file: ", err)
+		}
+		if msg.SenderIdentityID != testKey1ID || !bytes.Equal(msg.Contents, plaintext) {
+			t.Error("Sender's own copy didn't round-trip: ", msg.SenderIdentityID, string(msg.Contents))
+		}
+	}
+
+	// The same through an Encrypter with a key from elsewhere, as from a key file.
+	e, err := NewEncrypter(WithIdentity(testKey1), WithSelf(self), WithRecipients(recipient.PublicOnly()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	genCrypted, replyTo, err := e.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyTo.Wipe()
+	for _, key := range []*taber.Keys{self, recipient} {
+		if _, err = DecryptMessage(genCrypted, key); err != nil {
+			t.Error("Couldn't decrypt file encrypted with WithSelf: ", err)
+		}
 	}
 }
 
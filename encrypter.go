@@ -0,0 +1,366 @@
+package minilock
+
+import (
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// Encrypter encrypts files to a fixed set of recipients from a fixed sender,
+// and is configured with Options, so that new features don't break the
+// signatures of the EncryptFileContents family. Create one with NewEncrypter.
+// An Encrypter holds no per-message state and may be reused; call Wipe when
+// finished with it to wipe any keys it derived itself.
+type Encrypter struct {
+	filename   string
+	recipients []*taber.Keys
+	identity   *IdentityKeys
+	sender     *taber.Keys
+	self       *taber.Keys
+	replyTo    *taber.Keys
+	noSelf     bool
+	anonymous  bool
+	settings   encryptSettings
+
+	// Wipes for keys derived by options rather than passed in, and so owned here.
+	owned []func()
+}
+
+// Option configures an Encrypter.
+type Option func(*Encrypter) error
+
+// WithRecipients adds recipient keys to encrypt to.
+func WithRecipients(recipients ...*taber.Keys) Option {
+	return func(e *Encrypter) error {
+		e.recipients = append(e.recipients, recipients...)
+		return nil
+	}
+}
+
+// WithRecipientIDs adds recipients by their miniLock IDs.
+func WithRecipientIDs(recipientIDs ...string) Option {
+	return func(e *Encrypter) error {
+		for _, id := range recipientIDs {
+			recipient, err := taber.FromID(id)
+			if err != nil {
+				return err
+			}
+			e.recipients = append(e.recipients, recipient)
+		}
+		return nil
+	}
+}
+
+// WithPassphraseRecipient lets files also be decrypted, with
+// DecryptWithPassphrase, by anyone who knows passphrase, for sharing with people
+// who have no miniLock ID yet. It may be used on its own or alongside other
+// recipients. The passphrase is hardened with taber.DefaultKDFParams and a
+// random salt, but a file can still be attacked offline by guessing it, so it
+// must be strong. Only fminilock headers, the default, can hold passphrases.
+func WithPassphraseRecipient(passphrase string) Option {
+	return WithPassphraseRecipientParams(passphrase, taber.DefaultKDFParams)
+}
+
+// WithPassphraseRecipientParams is WithPassphraseRecipient with chosen scrypt
+// parameters, such as a greater cost for files that must resist attack for
+// longer.
+func WithPassphraseRecipientParams(passphrase string, params taber.KDFParams) Option {
+	return func(e *Encrypter) error {
+		slot, recipient, err := newPassphraseSlot(passphrase, params)
+		if err != nil {
+			return err
+		}
+		e.settings.passphraseSlots = append(e.settings.passphraseSlots, slot)
+		e.recipients = append(e.recipients, recipient)
+		return nil
+	}
+}
+
+// WithIdentity signs messages with identity, which must hold its private key.
+// The caller remains responsible for wiping it.
+func WithIdentity(identity *IdentityKeys) Option {
+	return func(e *Encrypter) error {
+		if identity != nil && !identity.HasPrivate() {
+			return ErrCannotSign
+		}
+		e.identity = identity
+		return nil
+	}
+}
+
+// WithEmailAndPassphrase derives the signing identity and the sender's own box
+// key from an email and passphrase, as EncryptFileContentsWithStrings does.
+func WithEmailAndPassphrase(email, passphrase string) Option {
+	return func(e *Encrypter) error {
+		self, identity, err := GenerateKeys(email, passphrase)
+		if err != nil {
+			return err
+		}
+		e.owned = append(e.owned, func() { self.Wipe() }, identity.Wipe)
+		e.self = self
+		e.identity = identity
+		return nil
+	}
+}
+
+// WithSelf sets the sender's own long-term box key, such as one from a key
+// file, which files are also encrypted to unless WithoutSelf is given. The
+// caller remains responsible for wiping it.
+func WithSelf(self *taber.Keys) Option {
+	return func(e *Encrypter) error {
+		e.self = self
+		return nil
+	}
+}
+
+// WithSender encrypts file info from sender rather than from a new random key
+// for each message. The caller remains responsible for wiping it.
+func WithSender(sender *taber.Keys) Option {
+	return func(e *Encrypter) error {
+		e.sender = sender
+		return nil
+	}
+}
+
+// WithReplyTo asks recipients to reply to replyTo rather than to a new random
+// key for each message.
+func WithReplyTo(replyTo *taber.Keys) Option {
+	return func(e *Encrypter) error {
+		e.replyTo = replyTo
+		return nil
+	}
+}
+
+// WithChunkSize sets the size of the chunks the file is encrypted in, between
+// taber.MinChunkSize and taber.MaxChunkSize: smaller for low-latency streaming,
+// larger for bulk throughput. The default, taber.ConstChunkSize, is the only
+// size older readers know; other sizes are recorded in the file info.
+func WithChunkSize(size int) Option {
+	return func(e *Encrypter) error {
+		if !taber.ValidChunkSize(size) {
+			return ErrBadChunkSize
+		}
+		e.settings.chunkSize = size
+		return nil
+	}
+}
+
+// WithHeaderVersion writes headers of the given version, such as
+// HeaderVersionBinary, rather than HeaderVersionFMiniLock. Files that need a
+// framed payload are written with the framed form of the version instead, and
+// can't be written as HeaderVersionMiniLock. Recipients need a version of this
+// package that knows it.
+func WithHeaderVersion(version int) Option {
+	return func(e *Encrypter) error {
+		if _, ok := headerFormats[version]; !ok {
+			return &VersionError{version}
+		}
+		e.settings.headerVersion = version
+		return nil
+	}
+}
+
+// WithoutSelf stops the sender's own key being added to the recipients.
+func WithoutSelf() Option {
+	return func(e *Encrypter) error {
+		e.noSelf = true
+		return nil
+	}
+}
+
+// WithFilename sets the filename stored in encrypted files.
+func WithFilename(filename string) Option {
+	return func(e *Encrypter) error {
+		e.filename = filename
+		return nil
+	}
+}
+
+// WithMetadata stores md, encrypted, in files, with its Size set to the length
+// of the plaintext. See MetadataFromFileInfo. Files with metadata have a framed
+// payload, and are written with the framed form of their header version, such
+// as HeaderVersionFramed.
+func WithMetadata(md *Metadata) Option {
+	return func(e *Encrypter) error {
+		e.settings.metadata = md
+		return nil
+	}
+}
+
+// WithCompression compresses contents with algorithm, such as
+// CompressionGzip, before encrypting them; recipients decompress them
+// transparently. Contents are compressed in independent blocks of 1 MiB, so
+// they can be decompressed as they are decrypted. The algorithm is recorded
+// in the payload frame, so compressed files are written with the framed form
+// of their header version.
+//
+// Compression makes the length of a file depend on its contents. Where an
+// attacker can place their own text in a file alongside secrets, and observe
+// the size of the result, they can learn the secrets a little at a time, as in
+// the CRIME and BREACH attacks on TLS. Only compress contents that are wholly
+// the sender's own, such as logs and exports.
+func WithCompression(algorithm string) Option {
+	return func(e *Encrypter) error {
+		if !validCompression(algorithm) {
+			return ErrUnknownCompression
+		}
+		e.settings.compression = algorithm
+		return nil
+	}
+}
+
+// WithPadding pads the payload frame under policy, such as PaddingPadme or
+// PaddingBlock(4096), so that the length of files says less about the length
+// of their contents. The padding and its length are encrypted and
+// authenticated along with the contents, and removed on decryption; padded
+// files are written with a framed header version.
+func WithPadding(policy string) Option {
+	return func(e *Encrypter) error {
+		if _, err := paddedLength(policy, 0); err != nil {
+			return err
+		}
+		e.settings.padding = policy
+		return nil
+	}
+}
+
+// WithDecoys adds random decoy entries to headers with fewer than count
+// recipients, so that they don't reveal how many there are. Every entry,
+// real or decoy, has the same length, so to an outsider decoys look the same
+// as real entries, and recipients take as long to find their entry whichever
+// it is. Entries are padded as if each of their IDs were 46 characters long,
+// the longest a miniLock ID can be, which makes each one a 617-byte box. In
+// the framed header versions, which mark the file info as framed, it is 637
+// bytes, and a non-default chunk size adds a little more. With its nonce,
+// each entry adds 862 bytes to a JSON header (890 framed) and 645 bytes to a
+// binary one (665 framed).
+func WithDecoys(count int) Option {
+	return func(e *Encrypter) error {
+		if count < 1 || count > DefaultLimits.MaxRecipients {
+			return ErrBadDecoyCount
+		}
+		e.settings.decoysUpTo = count
+		return nil
+	}
+}
+
+// WithDecoyBucket adds decoy entries, as for WithDecoys, to round the number of
+// entries up to a multiple of size; this reveals only roughly how many
+// recipients there are, however many there may be.
+func WithDecoyBucket(size int) Option {
+	return func(e *Encrypter) error {
+		if size < 1 || size > DefaultLimits.MaxRecipients {
+			return ErrBadDecoyCount
+		}
+		e.settings.decoyBucket = size
+		return nil
+	}
+}
+
+// WithoutFilename encrypts without a filename, in a smaller format tuned for
+// short messages such as chat texts and notifications; it can't be combined
+// with WithFilename. Decrypted messages have an empty Filename. Readers that
+// expect a 256-byte filename block fail to authenticate them.
+func WithoutFilename() Option {
+	return func(e *Encrypter) error {
+		e.settings.noFilename = true
+		return nil
+	}
+}
+
+// WithAnonymous sends messages anonymously, as EncryptFileContentsAnonymously
+// does: with no identity, signature or reply-to, from a new random key.
+func WithAnonymous() Option {
+	return func(e *Encrypter) error {
+		e.anonymous = true
+		return nil
+	}
+}
+
+// NewEncrypter returns an Encrypter configured by opts. Unless WithAnonymous is
+// given, an identity is required. There must be at least one recipient,
+// passphrase or copy to self.
+func NewEncrypter(opts ...Option) (*Encrypter, error) {
+	e := new(Encrypter)
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			e.Wipe()
+			return nil, err
+		}
+	}
+	if e.settings.noFilename && e.filename != "" {
+		e.Wipe()
+		return nil, ErrFilenameOptions
+	}
+	if e.anonymous {
+		if e.identity != nil || e.sender != nil || e.self != nil || e.replyTo != nil {
+			e.Wipe()
+			return nil, ErrAnonymousOptions
+		}
+	} else if e.identity == nil {
+		e.Wipe()
+		return nil, ErrNoSenderIdentity
+	}
+	// Passphrase slots are among the recipients, so this is a file nobody
+	// could open.
+	if len(e.recipients) == 0 && (e.noSelf || e.selfKey() == nil) {
+		e.Wipe()
+		return nil, ErrNoRecipients
+	}
+	return e, nil
+}
+
+// The key files are encrypted to for the sender's own copy: the key given by
+// WithSelf or WithEmailAndPassphrase, or else the sender key given by
+// WithSender. A new random sender key is no use for this, so without either
+// there is no copy to self.
+func (e *Encrypter) selfKey() *taber.Keys {
+	if e.self != nil {
+		return e.self
+	}
+	return e.sender
+}
+
+// Wipe wipes any keys derived by the Encrypter's options.
+func (e *Encrypter) Wipe() {
+	for _, wipe := range e.owned {
+		wipe()
+	}
+	e.owned = nil
+}
+
+// Encrypt encrypts fileContents, returning the miniLock file and the reply-to
+// key. If no reply-to key was given with WithReplyTo, a new random one is
+// returned, which the caller must keep to read replies and wipe afterwards.
+// For anonymous messages replyTo is nil.
+func (e *Encrypter) Encrypt(fileContents []byte) (miniLockContents []byte, replyTo *taber.Keys, err error) {
+	if e.anonymous {
+		miniLockContents, err = encryptFileContentsAnonymously(e.settings, e.filename, fileContents, e.recipients...)
+		return miniLockContents, nil, err
+	}
+	sender := e.sender
+	if sender == nil {
+		sender, err = EphemeralKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer sender.Wipe()
+	}
+	recipients := e.recipients
+	if self := e.selfKey(); self != nil && !e.noSelf {
+		recipients = append(recipients[:len(recipients):len(recipients)], self.PublicOnly())
+	}
+	replyTo = e.replyTo
+	if replyTo == nil {
+		replyTo, err = EphemeralKey()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	miniLockContents, err = encryptFileContents(e.settings, e.filename, fileContents, sender, replyTo, e.identity, recipients...)
+	if err != nil {
+		if e.replyTo == nil {
+			replyTo.Wipe()
+		}
+		return nil, nil, err
+	}
+	return miniLockContents, replyTo, nil
+}
@@ -0,0 +1,160 @@
+package minilock
+
+import (
+	"bytes"
+	"io"
+	mathrand "math/rand"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// Encrypt twice from the same deterministic random stream.
+func encryptTwice(t *testing.T, old, new func() ([]byte, error)) (oldOut, newOut []byte) {
+	defer func(r io.Reader) { randReader = r }(randReader)
+	var err error
+	randReader = mathrand.New(mathrand.NewSource(42))
+	oldOut, err = old()
+	if err != nil {
+		t.Fatal("Old entry point failed: ", err)
+	}
+	randReader = mathrand.New(mathrand.NewSource(42))
+	newOut, err = new()
+	if err != nil {
+		t.Fatal("Encrypter failed: ", err)
+	}
+	return oldOut, newOut
+}
+
+func Test_EncrypterMatchesEncryptFileContents(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	sender, _ := EphemeralKey()
+	replyTo, _ := EphemeralKey()
+	recipient, _ := EphemeralKey()
+	oldOut, newOut := encryptTwice(t, func() ([]byte, error) {
+		return EncryptFileContents("file.txt", plaintext, sender, replyTo, testKey1, recipient.PublicOnly())
+	}, func() ([]byte, error) {
+		e, err := NewEncrypter(WithFilename("file.txt"), WithSender(sender), WithReplyTo(replyTo),
+			WithIdentity(testKey1), WithRecipients(recipient.PublicOnly()), WithoutSelf(), WithChunkSize(taber.ConstChunkSize))
+		if err != nil {
+			return nil, err
+		}
+		out, _, err := e.Encrypt(plaintext)
+		return out, err
+	})
+	if !bytes.Equal(oldOut, newOut) {
+		t.Error("Encrypter output differs from EncryptFileContents")
+	}
+}
+
+func Test_EncrypterMatchesWithStrings(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	recipient, _ := EphemeralKey()
+	recipientID, _ := recipient.EncodeID()
+	var oldReplyTo, newReplyTo *taber.Keys
+	oldOut, newOut := encryptTwice(t, func() (out []byte, err error) {
+		out, oldReplyTo, err = EncryptFileContentsWithStrings("file.txt", plaintext, "cathalgarvey@some.where", "this is a password that totally works for minilock purposes", true, recipientID)
+		return out, err
+	}, func() (out []byte, err error) {
+		e, err := NewEncrypter(WithFilename("file.txt"), WithRecipientIDs(recipientID),
+			WithEmailAndPassphrase("cathalgarvey@some.where", "this is a password that totally works for minilock purposes"))
+		if err != nil {
+			return nil, err
+		}
+		defer e.Wipe()
+		out, newReplyTo, err = e.Encrypt(plaintext)
+		return out, err
+	})
+	if !bytes.Equal(oldOut, newOut) {
+		t.Error("Encrypter output differs from EncryptFileContentsWithStrings")
+	}
+	if !bytes.Equal(oldReplyTo.Public, newReplyTo.Public) {
+		t.Error("Encrypter reply-to key differs from EncryptFileContentsWithStrings")
+	}
+}
+
+func Test_EncrypterMatchesAnonymous(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	recipient, _ := EphemeralKey()
+	oldOut, newOut := encryptTwice(t, func() ([]byte, error) {
+		return EncryptFileContentsAnonymously("file.txt", plaintext, recipient.PublicOnly())
+	}, func() ([]byte, error) {
+		e, err := NewEncrypter(WithFilename("file.txt"), WithAnonymous(), WithRecipients(recipient.PublicOnly()))
+		if err != nil {
+			return nil, err
+		}
+		out, _, err := e.Encrypt(plaintext)
+		return out, err
+	})
+	if !bytes.Equal(oldOut, newOut) {
+		t.Error("Encrypter output differs from EncryptFileContentsAnonymously")
+	}
+}
+
+func Test_EncrypterOptionErrors(t *testing.T) {
+	if _, err := NewEncrypter(WithFilename("file.txt")); err != ErrNoSenderIdentity {
+		t.Error("Expected ErrNoSenderIdentity, got: ", err)
+	}
+	if _, err := NewEncrypter(WithAnonymous(), WithIdentity(testKey1)); err != ErrAnonymousOptions {
+		t.Error("Expected ErrAnonymousOptions, got: ", err)
+	}
+	if _, err := NewEncrypter(WithIdentity(testKey1), WithChunkSize(1024)); err != ErrBadChunkSize {
+		t.Error("Expected ErrBadChunkSize, got: ", err)
+	}
+	for name, opts := range map[string][]Option{
+		"without self": {WithIdentity(testKey1), WithoutSelf()},
+		"no self key":  {WithIdentity(testKey1)},
+		"anonymous":    {WithAnonymous()},
+	} {
+		if _, err := NewEncrypter(opts...); err != ErrNoRecipients {
+			t.Error("Expected ErrNoRecipients ", name, ", got: ", err)
+		}
+	}
+	public, err := IdentityFromID(testKey1ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewEncrypter(WithIdentity(public)); err != ErrCannotSign {
+		t.Error("Expected ErrCannotSign for a public-only identity, got: ", err)
+	}
+	// The older entry points have no options to check, so the entry itself refuses.
+	recipient, _ := EphemeralKey()
+	if _, err = EncryptFileContents("file.txt", []byte("contents"), recipient, recipient, public, recipient.PublicOnly()); err != ErrCannotSign {
+		t.Error("Expected ErrCannotSign encrypting from a public-only identity, got: ", err)
+	}
+}
+
+func Test_EncrypterChunkSize(t *testing.T) {
+	plaintext := make([]byte, 200<<10)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+	recipient, _ := EphemeralKey()
+	for _, chunkSize := range []int{64 << 10, taber.ConstChunkSize} {
+		e, err := NewEncrypter(WithIdentity(testKey1), WithRecipients(recipient), WithChunkSize(chunkSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, replyTo, err := e.Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replyTo.Wipe()
+		hdr, _, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, _, _, _, err := hdr.ExtractFileInfo(recipient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The default is left out, so that older readers can decrypt the file.
+		if recorded := fi.ChunkSize; (chunkSize == taber.ConstChunkSize && recorded != 0) || (chunkSize != taber.ConstChunkSize && recorded != chunkSize) {
+			t.Error("Chunk size ", chunkSize, " was recorded as ", recorded)
+		}
+		msg, err := DecryptMessage(encrypted, recipient)
+		if err != nil || !bytes.Equal(msg.Contents, plaintext) {
+			t.Error("Didn't round-trip with chunk size ", chunkSize, ": ", err)
+		}
+	}
+}
@@ -1,20 +1,136 @@
 package minilock
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
 
 var (
+	// ErrMalformed is matched by errors.Is for all errors caused by a file that
+	// doesn't follow the miniLock format, including *HeaderError.
+	ErrMalformed = taber.ErrMalformed
+	// ErrTampered is matched by errors.Is for all errors caused by content that
+	// failed authentication, including *SignatureError and *taber.ChunkAuthError.
+	ErrTampered = taber.ErrTampered
 	// ErrBadMagicBytes is returned when magic bytes didn't match expected 'miniLock'.
 	ErrBadMagicBytes = errors.New("Magic bytes didn't match expected 'miniLock'")
-	// ErrBadLengthPrefix is returned when header length exceeds file length.
-	ErrBadLengthPrefix = errors.New("Header length exceeds file length")
+	// ErrBadLengthPrefix is returned when header length is negative or exceeds file length.
+	ErrBadLengthPrefix = errors.New("Header length is negative or exceeds file length")
+	// ErrUnknownVersion is returned, as a *VersionError, when a header has a version this package doesn't know.
+	ErrUnknownVersion = errors.New("Unsupported miniLock header version")
+	// ErrBadBinaryHeader is returned when a binary header's entries don't fit its length, or repeat a nonce.
+	ErrBadBinaryHeader = errors.New("Binary header is truncated, has trailing data, or repeats a nonce")
+	// ErrUnsignedEntry is returned when a header version that requires sender identities holds an entry without one.
+	ErrUnsignedEntry = errors.New("DecryptInfo entry has no sender identity, which its header version requires")
+	// ErrTruncated is returned when a file is too short to hold a miniLock header.
+	ErrTruncated = errors.New("File is too short to be a miniLock file")
+	// ErrHeaderTooLarge is returned when a header is larger than the parser's limits allow.
+	ErrHeaderTooLarge = errors.New("Header is larger than allowed")
+	// ErrTooManyRecipients is returned when a header holds more decryptInfo entries than the parser's limits allow.
+	ErrTooManyRecipients = errors.New("Header has more recipients than allowed")
 	// ErrCTHashMismatch is returned when ciphertext hash did not match.
-	ErrCTHashMismatch = errors.New("Ciphertext hash did not match")
+	ErrCTHashMismatch error = &taber.CategorisedError{Msg: "Ciphertext hash did not match", Category: ErrTampered}
 	// ErrBadRecipient is returned when decryptInfo successfully decrypted but was addressed to another key.
 	ErrBadRecipient = errors.New("DecryptInfo successfully decrypted but was addressed to another key")
 	// ErrCannotDecrypt is returned when could not decrypt given ciphertext with given key or nonce.
+	// For whole files this means the file is simply not for this key.
 	ErrCannotDecrypt = errors.New("Could not decrypt given ciphertext with given key or nonce")
 	// ErrInsufficientEntropy is returned when got insufficient random bytes from RNG.
-	ErrInsufficientEntropy = errors.New("Got insufficient random bytes from RNG")
-	// ErrNilPlaintext is returned when got empty plaintext, can't encrypt.
-	ErrNilPlaintext = errors.New("Got empty plaintext, can't encrypt")
+	ErrInsufficientEntropy = taber.ErrInsufficientEntropy
+	// ErrNilPlaintext was returned when got empty plaintext, can't encrypt.
+	//
+	// Deprecated: empty plaintext is now encrypted as a single empty chunk, and
+	// this error is no longer returned.
+	ErrNilPlaintext = taber.ErrNilPlaintext
+	// ErrBadSignature is returned when a signature from a sender identity is invalid.
+	ErrBadSignature error = &taber.CategorisedError{Msg: "Invalid signature from sender identity", Category: ErrTampered}
+	// ErrNoSenderIdentity is returned when asked to encrypt without an identity outside anonymous mode.
+	ErrNoSenderIdentity = errors.New("No sender identity given; use anonymous mode to send without one")
+	// ErrAnonymousOptions is returned when anonymous mode is combined with sender, reply-to or identity options.
+	ErrAnonymousOptions = errors.New("Anonymous messages can't have a sender, reply-to or identity")
+	// ErrNoRecipients is returned when asked to encrypt a file that no recipient, self copy or passphrase could open.
+	ErrNoRecipients = errors.New("No recipients given; nobody could decrypt this file")
+	// ErrFilenameOptions is returned when asked to encrypt both with and without a filename.
+	ErrFilenameOptions = errors.New("Can't encrypt both with a filename and without one")
+	// ErrBadChunkSize is returned when asked to encrypt or decrypt with a chunk size the format doesn't support.
+	ErrBadChunkSize = taber.ErrBadChunkSize
+	// ErrFramedPayloadVersion is returned when a file needs a framed payload but its header version has no framed form, or when a file's payload framing doesn't match its header version.
+	ErrFramedPayloadVersion = errors.New("Payload framing doesn't match the header version")
+	// ErrBadFrame is returned when the lengths in a framed payload don't fit it, or its frame header isn't valid JSON.
+	ErrBadFrame error = &taber.CategorisedError{Msg: "Framed payload is malformed", Category: ErrMalformed}
+	// ErrUnknownFrameField is returned when a frame header holds a field this package doesn't know, which may change how the contents must be read.
+	ErrUnknownFrameField error = &taber.CategorisedError{Msg: "Frame header holds an unknown field", Category: ErrMalformed}
+	// ErrUnknownCompression is returned when asked to compress, or decompress a file, with an algorithm this package doesn't know.
+	ErrUnknownCompression = errors.New("Unsupported compression algorithm")
+	// ErrBadCompressedData is returned when decrypted contents are not valid for the compression recorded in the file info.
+	ErrBadCompressedData error = &taber.CategorisedError{Msg: "Compressed contents are corrupt", Category: ErrMalformed}
+	// ErrDecompressedTooLarge is returned when compressed contents expand beyond the decrypter's limits.
+	ErrDecompressedTooLarge = errors.New("Decompressed contents are larger than allowed")
+	// ErrUnknownPadding is returned when asked to pad with a policy this package doesn't know.
+	ErrUnknownPadding = errors.New("Unsupported padding policy")
+	// ErrBadPadding is returned when the padding length at the end of a framed payload is longer than the frame.
+	ErrBadPadding error = &taber.CategorisedError{Msg: "Padding is longer than the framed payload", Category: ErrMalformed}
+	// ErrBadDecoyCount is returned when asked for decoy entries outside 1 to DefaultLimits.MaxRecipients.
+	ErrBadDecoyCount = errors.New("Decoy entry count must be between 1 and the default recipient limit")
+	// ErrBadPassphraseSlot is returned when a passphrase slot has a salt of the wrong length or unacceptable KDF parameters.
+	ErrBadPassphraseSlot = errors.New("Passphrase slot has a bad salt or KDF parameters")
+	// ErrPassphraseSlotTooCostly is returned when deriving a passphrase slot's key would need more memory than the parser's limits allow.
+	ErrPassphraseSlotTooCostly = errors.New("Passphrase slot needs more memory than allowed")
+	// ErrPassphraseSlotVersion is returned when writing or reading passphrase slots in a header version that can't hold them.
+	ErrPassphraseSlotVersion = errors.New("Header version can't hold passphrase slots")
+	// ErrTooManyPassphraseSlots is returned when a header holds more passphrase slots than the parser's limits allow.
+	ErrTooManyPassphraseSlots = errors.New("Header has more passphrase slots than allowed")
+	// ErrNoPassphraseSlot is returned when asked to decrypt with a passphrase a file that has no passphrase slots.
+	ErrNoPassphraseSlot = errors.New("File can't be decrypted with a passphrase")
+	// ErrLegacyFile is returned when a file has no sender identity, as made by the original miniLock, and compatibility mode wasn't asked for.
+	ErrLegacyFile = errors.New("Legacy miniLock file without a sender identity; decrypt with AllowLegacy to read it unverified")
+	// ErrBadAnonymousEntry is returned when a decryptInfo entry flagged anonymous carries sender details.
+	ErrBadAnonymousEntry = errors.New("Anonymous decryptInfo entry carries sender identity or reply-to details")
+	// ErrMalformedSignature is returned when a signature block or signed message can't be parsed.
+	ErrMalformedSignature = errors.New("Signature block is malformed")
+	// ErrBadArmor is returned when an ASCII-armored miniLock file can't be decoded.
+	ErrBadArmor = errors.New("Armored miniLock file is malformed")
+	// ErrCannotSign is returned when asked to sign with a public-only identity.
+	ErrCannotSign = errors.New("Cannot sign with a public-only identity")
+	// ErrKeyFileEmpty is returned when a key file would contain, or contains, no keys.
+	ErrKeyFileEmpty = errors.New("Key file contains no keys")
+	// ErrKeyFileVersion is returned when a key file has an unknown format version.
+	ErrKeyFileVersion = errors.New("Key file has an unknown format version")
+	// ErrKeyFileLocked is returned when a key file is encrypted and no passphrase was given.
+	ErrKeyFileLocked = errors.New("Key file is encrypted, a passphrase is required")
+	// ErrKeyFilePassphrase is returned when a key file could not be decrypted with the given passphrase.
+	ErrKeyFilePassphrase = errors.New("Could not decrypt key file with given passphrase")
+	// ErrKeyFileCorrupt is returned when the keys in a key file don't match its IDs.
+	ErrKeyFileCorrupt = errors.New("Key file is corrupt: keys don't match stored IDs")
 )
+
+// HeaderError is returned when a miniLock header, or a decryptInfo entry or
+// fileInfo within it, can't be parsed. Err holds the underlying cause, such
+// as ErrBadMagicBytes or a JSON error. It matches ErrMalformed.
+type HeaderError struct {
+	Err error
+}
+
+func (e *HeaderError) Error() string { return "Malformed miniLock header: " + e.Err.Error() }
+
+// Unwrap returns the underlying cause.
+func (e *HeaderError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrMalformed.
+func (e *HeaderError) Is(target error) bool { return target == ErrMalformed }
+
+// SignatureError is returned when the signature on a decryptInfo entry, or on
+// signed content, does not verify. SignerID is the identity the signature
+// claims to be from, which has not been proven. It matches ErrBadSignature and
+// ErrTampered.
+type SignatureError struct {
+	SignerID string
+}
+
+func (e *SignatureError) Error() string {
+	return ErrBadSignature.Error() + " '" + e.SignerID + "'"
+}
+
+// Unwrap returns ErrBadSignature.
+func (e *SignatureError) Unwrap() error { return ErrBadSignature }
@@ -0,0 +1,66 @@
+package minilock
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_ErrorCategories(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	other, _ := EphemeralKey()
+	sender, _ := EphemeralKey()
+	genCrypted, err := EncryptFileContents("file.txt", []byte("Some file contents."), sender, sender, testKey1, recipient.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DecryptMessage(genCrypted, other)
+	if err != ErrCannotDecrypt || errors.Is(err, ErrMalformed) || errors.Is(err, ErrTampered) {
+		t.Error("Expected a bare ErrCannotDecrypt for a file not for this key, got: ", err)
+	}
+
+	tampered := append([]byte{}, genCrypted...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = DecryptMessage(tampered, recipient)
+	if !errors.Is(err, ErrTampered) || !errors.Is(err, ErrCTHashMismatch) {
+		t.Error("Expected ErrTampered for altered ciphertext, got: ", err)
+	}
+
+	malformed := append([]byte{}, genCrypted...)
+	malformed[12] = '['
+	_, err = DecryptMessage(malformed, recipient)
+	var headerErr *HeaderError
+	if !errors.As(err, &headerErr) || !errors.Is(err, ErrMalformed) {
+		t.Error("Expected a *HeaderError matching ErrMalformed for a broken header, got: ", err)
+	}
+	_, err = DecryptMessage(append([]byte("maxiLock"), genCrypted[8:]...), recipient)
+	if !errors.Is(err, ErrBadMagicBytes) || !errors.Is(err, ErrMalformed) {
+		t.Error("Expected ErrBadMagicBytes matching ErrMalformed, got: ", err)
+	}
+}
+
+func Test_SignatureError(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	sender, _ := EphemeralKey()
+	ephem, _ := EphemeralKey()
+	nonce, _ := makeFullNonce()
+	fi := &FileInfo{FileKey: make([]byte, 32), FileNonce: make([]byte, 16), FileHash: make([]byte, 32)}
+	DI, err := NewDecryptInfoEntry(nonce, fi, sender, recipient, sender, testKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Claim to be someone else.
+	DI.SenderIdentityID = testKey2ID
+	encDI, err := EncryptDecryptInfo(DI, nonce, ephem, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecryptDecryptInfo(encDI, nonce, ephem.PublicOnly(), recipient)
+	var sigErr *SignatureError
+	if !errors.As(err, &sigErr) || sigErr.SignerID != testKey2ID {
+		t.Fatal("Expected a *SignatureError naming the claimed signer, got: ", err)
+	}
+	if !errors.Is(err, ErrBadSignature) || !errors.Is(err, ErrTampered) || errors.Is(err, ErrMalformed) {
+		t.Error("SignatureError should match ErrBadSignature and ErrTampered only: ", err)
+	}
+}
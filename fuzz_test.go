@@ -0,0 +1,156 @@
+package minilock
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets below are run over their seeds and over the regression
+// corpus in testdata/fuzz by "go test"; run one with, for example,
+// "go test -fuzz FuzzParseFileContents". Crashers found while fuzzing are
+// written to testdata/fuzz and should be committed along with the fix.
+
+// Seed with every miniLock file in binary_samples.
+func addSampleFiles(f *testing.F) {
+	for _, name := range AssetNames() {
+		if !strings.HasSuffix(name, ".minilock") {
+			continue
+		}
+		sample, err := Asset(name)
+		if err != nil {
+			f.Fatal("Couldn't load test binary asset.")
+		}
+		f.Add(sample)
+	}
+}
+
+// Parsing and decrypting arbitrary input must fail cleanly, never panic or
+// allocate without bound.
+func FuzzParseFileContents(f *testing.F) {
+	// Deriving a key with scrypt here would make each fuzzing worker very slow
+	// to start, so seed with a file encrypted to a random key instead.
+	recipient, err := EphemeralKey()
+	if err != nil {
+		f.Fatal(err)
+	}
+	sample, err := EncryptFileContents("file.txt", []byte("Some file contents."), recipient, recipient, testKey1, recipient.PublicOnly())
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sample)
+	e, err := NewEncrypter(WithIdentity(testKey1), WithRecipients(recipient), WithHeaderVersion(HeaderVersionBinary))
+	if err != nil {
+		f.Fatal(err)
+	}
+	if sample, _, err = e.Encrypt([]byte("Some file contents.")); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sample)
+	addSampleFiles(f)
+	f.Add([]byte{})
+	f.Add([]byte("miniLock"))
+	f.Add([]byte("miniLock\xff\xff\xff\xff{}"))
+	f.Add([]byte("miniLock\x02\x00\x00\x00{}"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, ciphertext, err := ParseFileContents(data)
+		if err != nil {
+			return
+		}
+		header.DecryptMessage(ciphertext, recipient)
+	})
+}
+
+// Decrypting arbitrary decryptInfo plaintext, as a sender could encrypt to any
+// recipient, must fail cleanly; and any entry that passes must be signed or
+// explicitly anonymous.
+func FuzzDecryptDecryptInfo(f *testing.F) {
+	recipient, err := EphemeralKey()
+	if err != nil {
+		f.Fatal(err)
+	}
+	ephem, err := EphemeralKey()
+	if err != nil {
+		f.Fatal(err)
+	}
+	nonce := make([]byte, 24)
+	fi := &FileInfo{FileKey: make([]byte, 32), FileNonce: make([]byte, 16), FileHash: make([]byte, 32)}
+	signed, err := NewDecryptInfoEntry(nonce, fi, ephem, recipient, ephem, testKey1)
+	if err != nil {
+		f.Fatal(err)
+	}
+	anonymous, err := NewAnonymousDecryptInfoEntry(nonce, fi, ephem, recipient)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, DI := range []*DecryptInfoEntry{signed, anonymous} {
+		encoded, err := json.Marshal(DI)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(encoded)
+	}
+	f.Add([]byte("{}"))
+	f.Add([]byte(`{"anonymous":true}`))
+	f.Fuzz(func(t *testing.T, plain []byte) {
+		diEnc, err := ephem.Encrypt(plain, nonce, recipient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		DI, err := DecryptDecryptInfo(diEnc, nonce, ephem.PublicOnly(), recipient)
+		if err != nil {
+			return
+		}
+		if DI.Anonymous {
+			if DI.SenderIdentityID != "" || DI.Verification != "" || DI.ReplyToID != "" {
+				t.Fatal("Accepted an anonymous entry carrying sender details")
+			}
+		} else if DI.SenderIdentityID != testKey1ID {
+			t.Fatal("Accepted a signature from an identity that didn't make it: ", DI.SenderIdentityID)
+		}
+	})
+}
+
+// Any ID that imports must encode back to an ID for the same key.
+func FuzzIdentityFromID(f *testing.F) {
+	f.Add(testKey1ID)
+	f.Add(testKey2ID)
+	f.Add("")
+	f.Add("0OIl")
+	f.Fuzz(func(t *testing.T, ID string) {
+		identity, err := IdentityFromID(ID)
+		if err != nil {
+			return
+		}
+		encoded, err := identity.EncodeID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := IdentityFromID(encoded)
+		if err != nil || !bytes.Equal(again.Public, identity.Public) {
+			t.Fatal("Identity didn't survive re-encoding: ", ID, encoded)
+		}
+	})
+}
+
+// Unframing arbitrary payloads, as a sender could encrypt any, must fail
+// cleanly, and a body that passes must lie within the payload.
+func FuzzUnframe(f *testing.F) {
+	framed, err := frame(&frameHeader{Metadata: &Metadata{ContentType: "text/plain"}}, []byte("Some file contents."))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(framed)
+	f.Add([]byte{})
+	f.Add(make([]byte, frameLengthSize+framePadSize))
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		_, body, err := unframe(payload)
+		if err != nil {
+			return
+		}
+		if len(body) > len(payload)-frameLengthSize-framePadSize {
+			t.Fatal("Body is longer than the payload allows: ", len(body))
+		}
+	})
+}
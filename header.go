@@ -1,7 +1,9 @@
 package minilock
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"strconv"
 
 	"github.com/cathalgarvey/go-minilock/taber"
 )
@@ -12,11 +14,28 @@ type FileInfo struct {
 	FileKey   []byte `json:"fileKey"`
 	FileNonce []byte `json:"fileNonce"`
 	FileHash  []byte `json:"fileHash"`
+	// ChunkSize is the length of the file's chunks, if not taber.ConstChunkSize.
+	ChunkSize int `json:"chunkSize,omitempty"`
+	// Framed is whether the payload is framed, as it must be in headers of a
+	// framed version. Unlike the header version, it is authenticated.
+	Framed bool `json:"framed,omitempty"`
 }
 
+// Wipe zeroes the file key; call it when finished with a FileInfo.
+func (fi *FileInfo) Wipe() {
+	taber.WipeBytes(fi.FileKey)
+}
+
+// AnonymousSender is reported as the sender identity ID of anonymous messages,
+// which carry no identity or signature. It can never be a valid identity ID.
+const AnonymousSender = "anonymous"
+
 // DecryptInfoEntry is the container for the decryption instructions of "FileInfo",
 // also containing sender and recipient. It is encrypted to the recipient
 // with an ephemeral key to preserve privacy.
+// Anonymous entries have a random, single-use SenderID and no ReplyToID,
+// SenderIdentityID or Verification, so the recipient learns nothing about the
+// sender and nothing about the message is authenticated.
 type DecryptInfoEntry struct {
 	SenderID         string `json:"senderID"`
 	RecipientID      string `json:"recipientID"`
@@ -24,6 +43,7 @@ type DecryptInfoEntry struct {
 	ReplyToID        string `json:"replyToID"`
 	SenderIdentityID string `json:"senderIdentityID"`
 	Verification     string `json:"verification"`
+	Anonymous        bool   `json:"anonymous,omitempty"`
 }
 
 // SenderPubkey returns the pubkey of the sender who (allegedly) created this DecryptInfoEntry.
@@ -31,6 +51,21 @@ func (die *DecryptInfoEntry) SenderPubkey() (*taber.Keys, error) {
 	return taber.FromID(die.SenderID)
 }
 
+// SenderIdentity returns the sender's identity ID, AnonymousSender for
+// anonymous entries, or "" for legacy entries.
+func (die *DecryptInfoEntry) SenderIdentity() string {
+	if die.Anonymous {
+		return AnonymousSender
+	}
+	return die.SenderIdentityID
+}
+
+// Legacy entries, as made by the original miniLock and go-minilock, have only
+// a SenderID, RecipientID and FileInfoEnc.
+func (die *DecryptInfoEntry) isLegacy() bool {
+	return !die.Anonymous && die.SenderIdentityID == "" && die.Verification == ""
+}
+
 func (die *DecryptInfoEntry) contentToVerify() []byte {
 	contentToVerify := make([]byte, 0)
 	contentToVerify = append(contentToVerify, die.SenderID...)
@@ -51,13 +86,23 @@ type miniLockv1Header struct {
 	Version     int               `json:"version"`
 	Ephemeral   []byte            `json:"ephemeral"`
 	DecryptInfo map[string][]byte `json:"decryptInfo"`
+	// PassphraseSlots let the file be decrypted with passphrases, and may only
+	// appear in headers whose format allows them.
+	PassphraseSlots []passphraseSlot `json:"passphraseSlots,omitempty"`
 }
 
-// Keygens a new ephemeral key, returns the header plus this key.
-func prepareNewHeader() (*miniLockv1Header, *taber.Keys, error) {
+// Keygens a new ephemeral key, returns the header of the given version (or
+// HeaderVersionFMiniLock for 0) plus this key.
+func prepareNewHeader(version int) (*miniLockv1Header, *taber.Keys, error) {
+	if version == 0 {
+		version = HeaderVersionFMiniLock
+	}
+	if _, ok := headerFormats[version]; !ok {
+		return nil, nil, &VersionError{version}
+	}
 	hdr := new(miniLockv1Header)
-	hdr.Version = 1
-	ephem, err := taber.RandomKey()
+	hdr.Version = version
+	ephem, err := taber.RandomKeyFrom(randReader)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -66,33 +111,80 @@ func prepareNewHeader() (*miniLockv1Header, *taber.Keys, error) {
 	return hdr, ephem, nil
 }
 
-// Header data is pretty constant, so should be possible to predict length based
-// on number of entries in DecryptInfo map!
-// URGENT TODO: Refactor to do things intelligently, this is just a placeholder.
+// encodedLength is the length of the header when encoded in its version's
+// format, not counting magic bytes and length prefix.
 func (hdr *miniLockv1Header) encodedLength() int {
-	// Get minified JSON header and length.
-	encHeader, err := json.Marshal(hdr)
-	if err != nil {
-		return 0
-	}
-	return len(encHeader)
+	return hdr.format().encodedLength(hdr)
 }
 
-// Encode 'miniLock<int32 LE header length prefix><header JSON>' into "into",
+// Encode 'miniLock<int32 LE header length prefix><header>' into "into",
 // return "into" (in case of reallocations)
 func (hdr *miniLockv1Header) stuffSelf(into []byte) ([]byte, error) {
-	// Get minified JSON header and length.
-	encHeader, err := json.Marshal(hdr)
+	format, ok := headerFormats[hdr.Version]
+	if !ok {
+		return nil, &VersionError{hdr.Version}
+	}
+	if len(hdr.PassphraseSlots) > 0 && !format.passphraseSlots {
+		return nil, ErrPassphraseSlotVersion
+	}
+	into = append(into, magicBytes...)
+	// The length prefix is filled in once the header is written.
+	prefixAt := len(into)
+	into = append(into, 0, 0, 0, 0)
+	into, err := format.encode(hdr, into)
 	if err != nil {
 		return nil, err
 	}
-	hdrLength := len(encHeader)
-	hdrLengthLE, err := toLittleEndian(int32(hdrLength))
+	hdrLengthLE, err := toLittleEndian(int32(len(into) - prefixAt - 4))
 	if err != nil {
 		return nil, err
 	}
-	into = append(into, []byte("miniLock")...)
-	into = append(into, hdrLengthLE...)
-	into = append(into, encHeader...)
+	copy(into[prefixAt:], hdrLengthLE)
 	return into, nil
 }
+
+func encodeJSONHeader(hdr *miniLockv1Header, into []byte) ([]byte, error) {
+	// Get minified JSON header.
+	encHeader, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, err
+	}
+	return append(into, encHeader...), nil
+}
+
+func decodeJSONHeader(headerBytes []byte) (*miniLockv1Header, error) {
+	header := new(miniLockv1Header)
+	if err := json.Unmarshal(headerBytes, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// The length of the JSON header, counted without encoding it: every value but
+// the version is base64, which JSON never escapes.
+func jsonHeaderLength(hdr *miniLockv1Header) int {
+	length := len(`{"version":,"ephemeral":,"decryptInfo":}`)
+	length += len(strconv.Itoa(hdr.Version))
+	length += jsonBytesLength(hdr.Ephemeral)
+	length += jsonPassphraseSlotsLength(hdr.PassphraseSlots)
+	if hdr.DecryptInfo == nil {
+		return length + len("null")
+	}
+	length += len("{}")
+	for nonceS, encDI := range hdr.DecryptInfo {
+		// "nonce":"encDI",
+		length += len(nonceS) + len(`"":,`) + jsonBytesLength(encDI)
+	}
+	if len(hdr.DecryptInfo) > 0 {
+		// No comma after the last entry.
+		length--
+	}
+	return length
+}
+
+func jsonBytesLength(b []byte) int {
+	if b == nil {
+		return len("null")
+	}
+	return base64.StdEncoding.EncodedLen(len(b)) + 2
+}
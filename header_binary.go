@@ -0,0 +1,107 @@
+package minilock
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"sort"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// The binary header, HeaderVersionBinary or HeaderVersionBinaryFramed, holds
+// the same fields as the JSON header in a fixed layout, with all integers
+// little-endian:
+//
+//	version     uint8
+//	ephemeral   32 bytes
+//	entries     uint32, the number of decryptInfo entries
+//	then for each entry:
+//	  nonce     24 bytes
+//	  length    uint32
+//	  decryptInfo, length bytes
+//
+// Entries are written in order of nonce, and no nonce may repeat.
+const (
+	binaryHeaderFixedLength = 1 + 32 + 4
+	binaryEntryFixedLength  = 24 + 4
+)
+
+func binaryHeaderLength(hdr *miniLockv1Header) int {
+	length := binaryHeaderFixedLength
+	for _, encDI := range hdr.DecryptInfo {
+		length += binaryEntryFixedLength + len(encDI)
+	}
+	return length
+}
+
+func encodeBinaryHeader(hdr *miniLockv1Header, into []byte) ([]byte, error) {
+	if len(hdr.Ephemeral) != 32 {
+		return nil, taber.ErrBadKeyLength
+	}
+	nonces := make([]string, 0, len(hdr.DecryptInfo))
+	for nonceS := range hdr.DecryptInfo {
+		nonces = append(nonces, nonceS)
+	}
+	sort.Strings(nonces)
+	into = append(into, byte(hdr.Version))
+	into = append(into, hdr.Ephemeral...)
+	into = appendUint32(into, uint32(len(nonces)))
+	for _, nonceS := range nonces {
+		nonce, err := base64.StdEncoding.DecodeString(nonceS)
+		if err != nil {
+			return nil, err
+		}
+		if len(nonce) != 24 {
+			return nil, taber.ErrBadNonceLength
+		}
+		encDI := hdr.DecryptInfo[nonceS]
+		into = append(into, nonce...)
+		into = appendUint32(into, uint32(len(encDI)))
+		into = append(into, encDI...)
+	}
+	return into, nil
+}
+
+func decodeBinaryHeader(headerBytes []byte) (*miniLockv1Header, error) {
+	if len(headerBytes) < binaryHeaderFixedLength {
+		return nil, ErrBadBinaryHeader
+	}
+	header := &miniLockv1Header{
+		Version:     int(headerBytes[0]),
+		Ephemeral:   append([]byte(nil), headerBytes[1:33]...),
+		DecryptInfo: make(map[string][]byte),
+	}
+	count := binary.LittleEndian.Uint32(headerBytes[33:37])
+	rest := headerBytes[binaryHeaderFixedLength:]
+	// Every entry takes at least binaryEntryFixedLength bytes, so a count
+	// beyond that is a lie; check before looping on it.
+	if uint64(count) > uint64(len(rest)/binaryEntryFixedLength) {
+		return nil, ErrBadBinaryHeader
+	}
+	for i := uint32(0); i < count; i++ {
+		if len(rest) < binaryEntryFixedLength {
+			return nil, ErrBadBinaryHeader
+		}
+		nonceS := base64.StdEncoding.EncodeToString(rest[:24])
+		length := binary.LittleEndian.Uint32(rest[24:28])
+		rest = rest[binaryEntryFixedLength:]
+		if uint64(length) > uint64(len(rest)) {
+			return nil, ErrBadBinaryHeader
+		}
+		if _, repeated := header.DecryptInfo[nonceS]; repeated {
+			return nil, ErrBadBinaryHeader
+		}
+		header.DecryptInfo[nonceS] = append([]byte(nil), rest[:length]...)
+		rest = rest[length:]
+	}
+	if len(rest) != 0 {
+		return nil, ErrBadBinaryHeader
+	}
+	return header, nil
+}
+
+func appendUint32(into []byte, i uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], i)
+	return append(into, buf[:]...)
+}
@@ -0,0 +1,104 @@
+package minilock
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+func Test_BinaryHeaderRoundTrip(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	recipients := make([]*taber.Keys, 0, 50)
+	for i := 0; i < cap(recipients); i++ {
+		recipient, _ := EphemeralKey()
+		recipients = append(recipients, recipient)
+	}
+	sizes := make(map[int]int)
+	for _, version := range []int{HeaderVersionMiniLock, HeaderVersionFMiniLock, HeaderVersionBinary} {
+		e, err := NewEncrypter(WithIdentity(testKey1), WithRecipients(recipients...), WithFilename("file.txt"), WithoutSelf(), WithHeaderVersion(version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, replyTo, err := e.Encrypt(plaintext)
+		if err != nil {
+			t.Fatal("Couldn't encrypt with header version ", version, ": ", err)
+		}
+		replyTo.Wipe()
+		hdr, ciphertext, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal("Couldn't parse header version ", version, ": ", err)
+		}
+		if hdr.Version != version || len(hdr.DecryptInfo) != len(recipients) {
+			t.Error("Header didn't survive encoding: ", hdr.Info())
+		}
+		headerLength := len(encrypted) - len(magicBytes) - 4 - len(ciphertext)
+		if hdr.encodedLength() != headerLength {
+			t.Error("encodedLength was ", hdr.encodedLength(), " for a header of ", headerLength, " bytes, version ", version)
+		}
+		sizes[version] = headerLength
+		msg, err := DecryptMessage(encrypted, recipients[len(recipients)-1])
+		if err != nil {
+			t.Fatal("Couldn't decrypt header version ", version, ": ", err)
+		}
+		if !bytes.Equal(msg.Contents, plaintext) || msg.Verification != Signed {
+			t.Error("Decrypted message didn't match for header version ", version)
+		}
+	}
+	if sizes[HeaderVersionBinary] >= sizes[HeaderVersionFMiniLock] {
+		t.Error("Binary header wasn't smaller than JSON: ", sizes)
+	}
+	if _, err := NewEncrypter(WithIdentity(testKey1), WithHeaderVersion(99)); !errors.Is(err, ErrUnknownVersion) {
+		t.Error("Expected ErrUnknownVersion, got: ", err)
+	}
+}
+
+func Test_BinaryHeaderHostile(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	e, err := NewEncrypter(WithIdentity(testKey1), WithRecipients(recipient), WithHeaderVersion(HeaderVersionBinary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, _, err := e.Encrypt([]byte("Some file contents."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, _, err := ParseFileContents(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := encodeBinaryHeader(hdr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The header with one entry, and its entry's nonce repeated.
+	repeated := append(append([]byte(nil), valid...), valid[binaryHeaderFixedLength:]...)
+	repeated[33] = 2
+	lyingCount := append([]byte(nil), valid...)
+	lyingCount[33] = 0xff
+
+	for name, headerBytes := range map[string][]byte{
+		"empty":          {HeaderVersionBinary},
+		"truncated":      valid[:len(valid)-1],
+		"trailing data":  append(append([]byte(nil), valid...), 0),
+		"lying count":    lyingCount,
+		"repeated nonce": repeated,
+	} {
+		if _, err := decodeBinaryHeader(headerBytes); err != ErrBadBinaryHeader {
+			t.Error("Expected ErrBadBinaryHeader for ", name, ", got: ", err)
+		}
+	}
+
+	// A binary header must carry a binary version, and JSON a JSON version.
+	asJSON := append([]byte(nil), valid...)
+	asJSON[0] = HeaderVersionFMiniLock
+	for name, headerBytes := range map[string][]byte{
+		"binary header claiming a JSON version": asJSON,
+		"JSON header claiming a binary version": []byte(`{"version":3}`),
+	} {
+		if _, err := headerFormatOf(headerBytes); !errors.Is(err, ErrUnknownVersion) {
+			t.Error("Expected ErrUnknownVersion for ", name, ", got: ", err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package minilock
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/agl/ed25519"
+	"github.com/cathalgarvey/go-minilock/taber"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const keyFileVersion = 1
+
+// A key file holds a box key, an identity key or both, so that keys which
+// aren't derived from an email and passphrase (such as a server's random keys)
+// can survive restarts. The private keys are stored as the 32-byte box key
+// followed by the 64-byte identity key, whichever are present, either in the
+// clear or in a secretbox keyed by scrypt of a passphrase with a random salt.
+type keyFile struct {
+	Version    int              `json:"version"`
+	BoxID      string           `json:"boxID,omitempty"`
+	IdentityID string           `json:"identityID,omitempty"`
+	KDF        *taber.KDFParams `json:"kdf,omitempty"`
+	Salt       []byte           `json:"salt,omitempty"`
+	Nonce      []byte           `json:"nonce,omitempty"`
+	Private    []byte           `json:"private"`
+}
+
+// ExportKeys serialises keys and/or identity, either of which may be nil, to a
+// key file. If passphrase is empty the private keys are stored unencrypted,
+// which is only appropriate where the file is otherwise protected.
+func ExportKeys(keys *taber.Keys, identity *IdentityKeys, passphrase string) ([]byte, error) {
+	return exportKeys(keys, identity, passphrase, taber.DefaultKDFParams)
+}
+
+// ExportKeysWithParams is ExportKeys hardening the passphrase with chosen
+// scrypt parameters, such as a greater cost for keys that must resist attack
+// for longer.
+func ExportKeysWithParams(keys *taber.Keys, identity *IdentityKeys, passphrase string, params taber.KDFParams) ([]byte, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return exportKeys(keys, identity, passphrase, params)
+}
+
+// Separated from the above for testing purposes; cheap scrypt parameters.
+func exportKeys(keys *taber.Keys, identity *IdentityKeys, passphrase string, params taber.KDFParams) (encoded []byte, err error) {
+	var (
+		kf      = keyFile{Version: keyFileVersion}
+		private []byte
+	)
+	if keys == nil && identity == nil {
+		return nil, ErrKeyFileEmpty
+	}
+	defer func() { taber.WipeBytes(private) }()
+	if keys != nil {
+		if !keys.HasPrivate() {
+			return nil, taber.ErrPrivateKeyOpOnly
+		}
+		kf.BoxID, err = keys.EncodeID()
+		if err != nil {
+			return nil, err
+		}
+		private = append(private, keys.Private...)
+	}
+	if identity != nil {
+		if len(identity.Private) != ed25519.PrivateKeySize {
+			return nil, taber.ErrPrivateKeyOpOnly
+		}
+		kf.IdentityID, err = identity.EncodeID()
+		if err != nil {
+			return nil, err
+		}
+		private = append(private, identity.Private...)
+	}
+	if passphrase == "" {
+		kf.Private = private
+		return json.Marshal(kf)
+	}
+	kf.KDF = &params
+	kf.Salt, err = randBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	kf.Nonce, err = makeFullNonce()
+	if err != nil {
+		return nil, err
+	}
+	key, err := taber.HardenWithParams(kf.Salt, passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	defer taber.WipeBytes(key)
+	kf.Private = secretbox.Seal(nil, private, (*[24]byte)(kf.Nonce), (*[32]byte)(key))
+	return json.Marshal(kf)
+}
+
+// ImportKeys loads the keys stored in a key file by ExportKeys. Either returned
+// key may be nil if the file did not contain one. If the file is encrypted and
+// passphrase is empty, ErrKeyFileLocked is returned, so callers can find out
+// whether to ask for a passphrase.
+func ImportKeys(encoded []byte, passphrase string) (keys *taber.Keys, identity *IdentityKeys, err error) {
+	var (
+		kf      keyFile
+		private []byte
+	)
+	if err = json.Unmarshal(encoded, &kf); err != nil {
+		return nil, nil, err
+	}
+	if kf.Version != keyFileVersion {
+		return nil, nil, ErrKeyFileVersion
+	}
+	expectedLength := 0
+	if kf.BoxID != "" {
+		expectedLength += 32
+	}
+	if kf.IdentityID != "" {
+		expectedLength += ed25519.PrivateKeySize
+	}
+	if expectedLength == 0 {
+		return nil, nil, ErrKeyFileEmpty
+	}
+	if kf.KDF == nil {
+		private = kf.Private
+	} else {
+		if passphrase == "" {
+			return nil, nil, ErrKeyFileLocked
+		}
+		if len(kf.Nonce) != 24 {
+			return nil, nil, ErrKeyFileCorrupt
+		}
+		key, err := taber.HardenWithParams(kf.Salt, passphrase, *kf.KDF)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer taber.WipeBytes(key)
+		var ok bool
+		private, ok = secretbox.Open(nil, kf.Private, (*[24]byte)(kf.Nonce), (*[32]byte)(key))
+		if !ok {
+			return nil, nil, ErrKeyFilePassphrase
+		}
+	}
+	defer taber.WipeBytes(private)
+	if len(private) != expectedLength {
+		return nil, nil, ErrKeyFileCorrupt
+	}
+	if kf.BoxID != "" {
+		keys, err = taber.FromPrivate(private[:32])
+		if err != nil {
+			return nil, nil, err
+		}
+		private = private[32:]
+		if id, err := keys.EncodeID(); err != nil || id != kf.BoxID {
+			keys.Wipe()
+			return nil, nil, ErrKeyFileCorrupt
+		}
+	}
+	if kf.IdentityID != "" {
+		// The seed is the first half of an ed25519 private key.
+		identity, err = identityFromSeed(private[:32])
+		if err != nil {
+			if keys != nil {
+				keys.Wipe()
+			}
+			return nil, nil, err
+		}
+		if id, err := identity.EncodeID(); err != nil || id != kf.IdentityID || !bytes.Equal(identity.Private, private) {
+			if keys != nil {
+				keys.Wipe()
+			}
+			identity.Wipe()
+			return nil, nil, ErrKeyFileCorrupt
+		}
+	}
+	return keys, identity, nil
+}
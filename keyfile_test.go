@@ -0,0 +1,100 @@
+package minilock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// Cheap enough to not dominate the test run.
+var testKDFParams = taber.KDFParams{LogN: 10, R: 8, P: 1}
+
+func Test_KeyFileRoundTrip(t *testing.T) {
+	keys, err := EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := RandomIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, passphrase := range []string{"", "key file passphrase"} {
+		encoded, err := exportKeys(keys, identity, passphrase, testKDFParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if passphrase != "" && bytes.Contains(encoded, keys.Private) {
+			t.Error("Encrypted key file contains the raw private key")
+		}
+		keys2, identity2, err := ImportKeys(encoded, passphrase)
+		if err != nil {
+			t.Fatal("Failed to import key file: ", err)
+		}
+		if !bytes.Equal(keys.Private, keys2.Private) || !bytes.Equal(keys.Public, keys2.Public) {
+			t.Error("Imported box key did not match exported box key")
+		}
+		if !bytes.Equal(identity.Private, identity2.Private) || !bytes.Equal(identity.Public, identity2.Public) {
+			t.Error("Imported identity did not match exported identity")
+		}
+	}
+
+	encoded, err := exportKeys(keys, nil, "right", testKDFParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = ImportKeys(encoded, ""); err != ErrKeyFileLocked {
+		t.Error("Expected ErrKeyFileLocked without a passphrase, got:", err)
+	}
+	if _, _, err = ImportKeys(encoded, "wrong"); err != ErrKeyFilePassphrase {
+		t.Error("Expected ErrKeyFilePassphrase with the wrong passphrase, got:", err)
+	}
+	keys2, identity2, err := ImportKeys(encoded, "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity2 != nil || !bytes.Equal(keys.Public, keys2.Public) {
+		t.Error("Box-only key file did not import as a box key alone")
+	}
+
+	other, err := EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, _ := other.EncodeID()
+	tampered := bytes.Replace(mustExport(t, keys), []byte(mustEncodeID(t, keys)), []byte(otherID), 1)
+	if _, _, err = ImportKeys(tampered, ""); err != ErrKeyFileCorrupt {
+		t.Error("Expected ErrKeyFileCorrupt for mismatched ID, got:", err)
+	}
+}
+
+func mustExport(t *testing.T, keys *taber.Keys) []byte {
+	encoded, err := ExportKeys(keys, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func mustEncodeID(t *testing.T, keys *taber.Keys) string {
+	id, err := keys.EncodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func Test_ExportKeysWithParams(t *testing.T) {
+	keys, _ := EphemeralKey()
+	if _, err := ExportKeysWithParams(keys, nil, "passphrase", taber.KDFParams{LogN: 40, R: 8, P: 1}); err != taber.ErrBadKDFParams {
+		t.Error("Expected ErrBadKDFParams, got: ", err)
+	}
+	exported, err := ExportKeysWithParams(keys, nil, "passphrase", testKDFParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _, err := ImportKeys(exported, "passphrase")
+	if err != nil || !bytes.Equal(imported.Private, keys.Private) {
+		t.Error("Keys exported with chosen parameters didn't import: ", err)
+	}
+}
@@ -17,9 +17,30 @@ func GenerateKey(email string, passphrase string) (*taber.Keys, error) {
 	return taber.FromEmailAndPassphrase(email, passphrase)
 }
 
+// GenerateKeys derives both the box key and the identity for an email address
+// and passphrase, with a single scrypt run; the results are the same as those
+// of GenerateKey and IdentityFromEmailAndPassphrase.
+func GenerateKeys(email string, passphrase string) (*taber.Keys, *IdentityKeys, error) {
+	ppScrypt, err := taber.Harden(email, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer taber.WipeBytes(ppScrypt)
+	keys, err := taber.FromPrivate(ppScrypt)
+	if err != nil {
+		return nil, nil, err
+	}
+	identity, err := identityFromSeed(ppScrypt)
+	if err != nil {
+		keys.Wipe()
+		return nil, nil, err
+	}
+	return keys, identity, nil
+}
+
 // EphemeralKey generates a fully random key, usually for ephemeral uses.
 func EphemeralKey() (*taber.Keys, error) {
-	return taber.RandomKey()
+	return taber.RandomKeyFrom(randReader)
 }
 
 // ImportID imports a miniLock ID as a public key.
@@ -38,22 +59,60 @@ type Encodable interface {
 	EncodeID() (string, error)
 }
 
+// IdentityKeys is an ed25519 keypair used to sign DecryptInfoEntries, proving
+// which identity sent a file independently of the ephemeral box keys.
 type IdentityKeys struct {
+	// May be empty for identities imported from an ID.
 	Private []byte
 	Public  []byte
+
+	// Locked memory backing Private, for keys generated by this package.
+	secure *taber.SecureBuffer
 }
 
+// IdentityFromEmailAndPassphrase derives an identity keypair using the same
+// hardening scheme as GenerateKey.
 func IdentityFromEmailAndPassphrase(guid, passphrase string) (*IdentityKeys, error) {
 	ppScrypt, err := taber.Harden(guid, passphrase)
 	if err != nil {
 		return nil, err
 	}
-	scryptReader := bytes.NewReader(ppScrypt)
-	public, private, err := ed25519.GenerateKey(scryptReader)
+	defer taber.WipeBytes(ppScrypt)
+	return identityFromSeed(ppScrypt)
+}
+
+// RandomIdentity generates a fully random identity, for servers and other
+// uses where the identity is stored in a key file rather than remembered.
+func RandomIdentity() (*IdentityKeys, error) {
+	seed, err := randBytes(32)
 	if err != nil {
 		return nil, err
 	}
-	return &IdentityKeys{Private: private[:], Public: public[:]}, nil
+	defer taber.WipeBytes(seed)
+	return identityFromSeed(seed)
+}
+
+// Generate an identity keypair from 32 bytes of seed material, keeping the
+// private key in locked memory and wiping the intermediate copy.
+func identityFromSeed(seed []byte) (*IdentityKeys, error) {
+	public, private, err := ed25519.GenerateKey(bytes.NewReader(seed))
+	if err != nil {
+		return nil, err
+	}
+	secure := taber.NewSecureBuffer(ed25519.PrivateKeySize)
+	copy(secure.Bytes(), private[:])
+	taber.WipeBytes(private[:])
+	return &IdentityKeys{Private: secure.Bytes(), Public: public[:], secure: secure}, nil
+}
+
+// Wipe zeroes the private half of the identity; calling this method when
+// finished with an identity is strongly advised to prevent compromise.
+func (iks *IdentityKeys) Wipe() {
+	if iks.secure != nil {
+		iks.secure.Wipe()
+	} else {
+		taber.WipeBytes(iks.Private)
+	}
 }
 
 // EncodeID generate base58-encoded pubkey + 1-byte blake2s checksum as a string.
@@ -91,13 +150,33 @@ func (iks *IdentityKeys) checksum() ([]byte, error) {
 	return checksum, nil
 }
 
+// HasPrivate returns whether this identity holds a private key it can sign
+// with, rather than being public-only.
+func (iks *IdentityKeys) HasPrivate() bool {
+	return len(iks.Private) == ed25519.PrivateKeySize
+}
+
+// Sign signs content with the identity's private key, returning nil if this
+// is a public-only identity.
 func (iks *IdentityKeys) Sign(content []byte) []byte {
-	var pk [ed25519.PrivateKeySize]byte
-	copy(pk[:], iks.Private)
-	signature := ed25519.Sign(&pk, content)
+	if !iks.HasPrivate() {
+		return nil
+	}
+	// Sign with the private key in place rather than leaving another copy around.
+	signature := ed25519.Sign((*[ed25519.PrivateKeySize]byte)(iks.Private), content)
 	return signature[:]
 }
 
+// Verify returns whether sig is a valid signature by this identity over content.
+func (iks *IdentityKeys) Verify(content, sig []byte) bool {
+	if len(iks.Public) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify((*[ed25519.PublicKeySize]byte)(iks.Public), content, (*[ed25519.SignatureSize]byte)(sig))
+}
+
+// IdentityFromID imports an identity ID as a public-only identity, which can
+// verify signatures.
 func IdentityFromID(ID string) (*IdentityKeys, error) {
 	keyCSbuf, err := base58.StdEncoding.Decode([]byte(ID))
 	if err != nil {
@@ -0,0 +1,23 @@
+package minilock
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_GenerateKeys(t *testing.T) {
+	keys, identity, err := GenerateKeys("cathalgarvey@some.where", "this is a password that totally works for minilock purposes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(identity.Private, testKey1.Private) {
+		t.Error("GenerateKeys identity did not match IdentityFromEmailAndPassphrase")
+	}
+	boxKey, err := GenerateKey("cathalgarvey@some.where", "this is a password that totally works for minilock purposes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(keys.Private, boxKey.Private) || !bytes.Equal(keys.Public, boxKey.Public) {
+		t.Error("GenerateKeys box key did not match GenerateKey")
+	}
+}
@@ -0,0 +1,40 @@
+package minilock
+
+import "github.com/cathalgarvey/go-minilock/taber"
+
+// KeySource supplies the keys a file might have been sent to, such as a
+// keyring holding current, rotated and shared team keys.
+type KeySource interface {
+	// DecryptionKeys returns the candidate keys, in order of preference.
+	DecryptionKeys() ([]*taber.Keys, error)
+}
+
+// KeyList is a KeySource of fixed keys.
+type KeyList []*taber.Keys
+
+// DecryptionKeys returns the keys in the list.
+func (kl KeyList) DecryptionKeys() ([]*taber.Keys, error) {
+	return kl, nil
+}
+
+// DecryptWithKeys parses a miniLock file and decrypts it with whichever of
+// keys it was sent to; Message.RecipientID tells which. Every key is tried
+// against every entry, at the cost of one key agreement per key. If none of
+// the keys can decrypt the file the error is ErrCannotDecrypt.
+func DecryptWithKeys(fileContents []byte, keys ...*taber.Keys) (*Message, error) {
+	return DecryptWithKeySource(fileContents, KeyList(keys))
+}
+
+// DecryptWithKeySource is DecryptWithKeys for the keys from source, with
+// options.
+func DecryptWithKeySource(fileContents []byte, source KeySource, opts ...DecryptOption) (*Message, error) {
+	header, ciphertext, err := ParseFileContentsWithLimits(fileContents, newDecryptConfig(opts).limits)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := source.DecryptionKeys()
+	if err != nil {
+		return nil, err
+	}
+	return header.DecryptMessageWithKeys(ciphertext, keys, opts...)
+}
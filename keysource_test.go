@@ -0,0 +1,56 @@
+package minilock
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+type failingKeySource struct{}
+
+var errKeySource = errors.New("keyring is locked")
+
+func (failingKeySource) DecryptionKeys() ([]*taber.Keys, error) {
+	return nil, errKeySource
+}
+
+func Test_DecryptWithKeys(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	keys := make([]*taber.Keys, 0, 4)
+	for i := 0; i < cap(keys); i++ {
+		key, _ := EphemeralKey()
+		keys = append(keys, key)
+	}
+	others := make([]*taber.Keys, 0, 3)
+	for i := 0; i < cap(others); i++ {
+		other, _ := EphemeralKey()
+		others = append(others, other)
+	}
+	// Sent to the third key, among strangers and decoys.
+	encrypted := mustEncrypt(t, plaintext, WithIdentity(testKey1), WithRecipients(append(others, keys[2])...), WithFilename("file.txt"), WithoutSelf(), WithDecoys(8))
+
+	// Public-only keys can't match, but don't stop the others being tried.
+	msg, err := DecryptWithKeys(encrypted, keys[0].PublicOnly(), keys[1], keys[2], keys[3])
+	if err != nil {
+		t.Fatal("Couldn't decrypt with several keys: ", err)
+	}
+	wantID, _ := keys[2].EncodeID()
+	if msg.RecipientID != wantID || !bytes.Equal(msg.Contents, plaintext) {
+		t.Error("Expected message for ", wantID, ", got one for ", msg.RecipientID)
+	}
+	if _, err = DecryptWithKeys(encrypted, keys[0], keys[1], keys[3]); err != ErrCannotDecrypt {
+		t.Error("Expected ErrCannotDecrypt without the right key, got: ", err)
+	}
+	if _, err = DecryptWithKeys(encrypted); err != ErrCannotDecrypt {
+		t.Error("Expected ErrCannotDecrypt with no keys, got: ", err)
+	}
+	if _, err = DecryptWithKeySource(encrypted, failingKeySource{}); err != errKeySource {
+		t.Error("Expected the key source's error, got: ", err)
+	}
+	msg, err = DecryptWithKeySource(encrypted, KeyList(keys))
+	if err != nil || msg.RecipientID != wantID {
+		t.Error("Couldn't decrypt with a KeyList: ", err)
+	}
+}
@@ -0,0 +1,135 @@
+package minilock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// The legacy vector has a miniLock v1 decryptInfo entry, with only senderID,
+// recipientID and fileInfo; the fminilock vectors carry an identity as well.
+// All are encrypted to the key for cathalgarvey@some.where. Apart from mye.go,
+// which predates them, they were written by this package, so they pin down the
+// formats rather than prove interoperability with other implementations.
+const (
+	legacyVectorSenderID    = "2FSApXJiXsRaJh5tdB6bgTSCKtGiJUGuGdormhYJoBRQzj"
+	fminilockVectorSenderID = "2CgNEGNcgriWBYC4254hU2xn43iBdBkkUBD6fDEP4qGTe"
+)
+
+func loadVector(t *testing.T, name string) (ciphertext, plaintext []byte) {
+	ciphertext, err := Asset("binary_samples/" + name + ".minilock")
+	if err != nil {
+		t.Fatal("Couldn't load test binary asset.")
+	}
+	plaintext, err = Asset("binary_samples/" + name)
+	if err != nil {
+		t.Fatal("Couldn't load test binary asset.")
+	}
+	return ciphertext, plaintext
+}
+
+// Decrypts reading only the fields a miniLock v1 decoder knows, ignoring
+// identities. It shares this package's parser and crypto, so it checks which
+// fields a file depends on, not how another implementation reads it.
+func legacyDecrypt(fileContents []byte, recipientKey *taber.Keys) (senderID, filename string, contents []byte, err error) {
+	header, ciphertext, err := ParseFileContents(fileContents)
+	if err != nil {
+		return "", "", nil, err
+	}
+	ephem := &taber.Keys{Public: header.Ephemeral}
+	for nonceS, encDI := range header.DecryptInfo {
+		nonce, err := base64.StdEncoding.DecodeString(nonceS)
+		if err != nil {
+			return "", "", nil, err
+		}
+		plain, err := recipientKey.Decrypt(encDI, nonce, ephem)
+		if err != nil {
+			continue
+		}
+		var DI struct {
+			SenderID    string `json:"senderID"`
+			RecipientID string `json:"recipientID"`
+			FileInfo    []byte `json:"fileInfo"`
+		}
+		if err = json.Unmarshal(plain, &DI); err != nil {
+			return "", "", nil, err
+		}
+		sender, err := taber.FromID(DI.SenderID)
+		if err != nil {
+			return "", "", nil, err
+		}
+		plain, err = recipientKey.Decrypt(DI.FileInfo, nonce, sender)
+		if err != nil {
+			return "", "", nil, err
+		}
+		fi := new(FileInfo)
+		if err = json.Unmarshal(plain, fi); err != nil {
+			return "", "", nil, err
+		}
+		filename, contents, err = fi.DecryptFile(ciphertext)
+		return DI.SenderID, filename, contents, err
+	}
+	return "", "", nil, ErrCannotDecrypt
+}
+
+func Test_LegacyVectors(t *testing.T) {
+	recipient, err := GenerateKey("cathalgarvey@some.where", "this is a password that totally works for minilock purposes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recipient.Wipe()
+
+	// Legacy files are refused unless compatibility mode is asked for.
+	legacy, legacyPlain := loadVector(t, "legacy.txt")
+	if _, err = DecryptMessage(legacy, recipient); err != ErrLegacyFile {
+		t.Error("Expected ErrLegacyFile without AllowLegacy, got: ", err)
+	}
+	msg, err := DecryptMessage(legacy, recipient, AllowLegacy())
+	if err != nil {
+		t.Fatal("Couldn't decrypt legacy vector in compatibility mode: ", err)
+	}
+	if msg.Verification != Unverified || msg.SenderIdentityID != "" {
+		t.Error("Legacy vector should be unverified with no identity, got: ", msg.Verification, msg.SenderIdentityID)
+	}
+	if msg.SenderID != legacyVectorSenderID || msg.Filename != "legacy.txt" || !bytes.Equal(msg.Contents, legacyPlain) {
+		t.Error("Legacy vector decrypted wrongly: ", msg.SenderID, msg.Filename)
+	}
+	senderID, filename, contents, err := legacyDecrypt(legacy, recipient)
+	if err != nil || senderID != legacyVectorSenderID || filename != "legacy.txt" || !bytes.Equal(contents, legacyPlain) {
+		t.Error("Legacy decoder couldn't read legacy vector: ", err)
+	}
+
+	// fminilock files stay signed in compatibility mode, and are readable by
+	// a legacy decoder that ignores the version and the identity fields.
+	// fminilock.txt is written as this package writes files now; mye.go
+	// predates header versions.
+	for _, tc := range []struct {
+		name, senderIdentityID, senderID string
+		version                          int
+	}{
+		{"fminilock.txt", testKey2ID, fminilockVectorSenderID, HeaderVersionFMiniLock},
+		{"mye.go", testKey1ID, "", HeaderVersionMiniLock},
+	} {
+		vector, plain := loadVector(t, tc.name)
+		if hdr, _, err := ParseFileContents(vector); err != nil || hdr.Version != tc.version {
+			t.Error("Expected ", tc.name, " to have header version ", tc.version, ": ", err)
+		}
+		msg, err = DecryptMessage(vector, recipient, AllowLegacy())
+		if err != nil {
+			t.Fatal("Couldn't decrypt fminilock vector: ", tc.name, err)
+		}
+		if msg.Verification != Signed || msg.SenderIdentityID != tc.senderIdentityID {
+			t.Error("fminilock vector should be signed by ", tc.senderIdentityID, ", got: ", msg.Verification, msg.SenderIdentityID)
+		}
+		if tc.senderID != "" && msg.SenderID != tc.senderID {
+			t.Error("Unexpected sender for fminilock vector: ", msg.SenderID)
+		}
+		senderID, filename, contents, err = legacyDecrypt(vector, recipient)
+		if err != nil || senderID != msg.SenderID || filename != tc.name || !bytes.Equal(contents, plain) {
+			t.Error("Legacy decoder couldn't read fminilock vector: ", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package minilock
+
+import (
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// Limits bounds the resources a miniLock header may make a parser use, so that
+// hostile files can't exhaust memory before anything has been authenticated.
+type Limits struct {
+	// MaxHeaderSize is the largest header, in bytes, that will be parsed. If
+	// zero, DefaultLimits.MaxHeaderSize applies.
+	MaxHeaderSize int
+	// MaxRecipients is the most decryptInfo entries a header may hold. If
+	// zero, DefaultLimits.MaxRecipients applies.
+	MaxRecipients int
+	// MaxDecompressedSize is the most bytes compressed contents may expand
+	// to. If zero, DefaultLimits.MaxDecompressedSize applies.
+	MaxDecompressedSize int64
+	// MaxPassphraseSlots is the most passphrase slots a header may hold, each
+	// of which costs an scrypt derivation to try. If zero,
+	// DefaultLimits.MaxPassphraseSlots applies.
+	MaxPassphraseSlots int
+	// MaxKDFMemory is the most memory, in bytes, that deriving the key of any
+	// one passphrase slot may need. Slots needing more are refused when the
+	// header is parsed, before any derivation. It can lower, but not raise,
+	// taber.MaxKDFMemory. If zero, DefaultLimits.MaxKDFMemory applies.
+	MaxKDFMemory int64
+}
+
+// DefaultLimits are used by ParseFileContents, and are generous enough for any
+// file made by miniLock or this package to thousands of recipients.
+var DefaultLimits = Limits{
+	MaxHeaderSize:       8 << 20,
+	MaxRecipients:       8192,
+	MaxDecompressedSize: 1 << 30,
+	MaxPassphraseSlots:  4,
+	MaxKDFMemory:        taber.MaxKDFMemory,
+}
+
+func (l Limits) maxHeaderSize() int {
+	if l.MaxHeaderSize == 0 {
+		return DefaultLimits.MaxHeaderSize
+	}
+	return l.MaxHeaderSize
+}
+
+func (l Limits) maxRecipients() int {
+	if l.MaxRecipients == 0 {
+		return DefaultLimits.MaxRecipients
+	}
+	return l.MaxRecipients
+}
+
+func (l Limits) maxDecompressedSize() int64 {
+	if l.MaxDecompressedSize == 0 {
+		return DefaultLimits.MaxDecompressedSize
+	}
+	return l.MaxDecompressedSize
+}
+
+func (l Limits) maxPassphraseSlots() int {
+	if l.MaxPassphraseSlots == 0 {
+		return DefaultLimits.MaxPassphraseSlots
+	}
+	return l.MaxPassphraseSlots
+}
+
+func (l Limits) maxKDFMemory() int64 {
+	if l.MaxKDFMemory == 0 {
+		return DefaultLimits.MaxKDFMemory
+	}
+	return l.MaxKDFMemory
+}
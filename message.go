@@ -0,0 +1,187 @@
+package minilock
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// Verification describes what a decrypted message's header proves about who
+// sent it.
+type Verification int
+
+const (
+	// Signed messages carry a valid signature by SenderIdentityID over their
+	// decryptInfo entry.
+	Signed Verification = iota
+	// Anonymous messages carry no identity or signature at all; nothing is
+	// known about the sender.
+	Anonymous
+	// Unverified messages are legacy miniLock files, accepted only with
+	// AllowLegacy. Their file info was boxed from SenderID, but nothing ties
+	// that key to a signing identity.
+	Unverified
+)
+
+func (v Verification) String() string {
+	switch v {
+	case Signed:
+		return "signed"
+	case Anonymous:
+		return "anonymous"
+	case Unverified:
+		return "unverified"
+	default:
+		return "unknown"
+	}
+}
+
+// HeaderInfo holds what can be learned of a miniLock header without a key.
+type HeaderInfo struct {
+	Version int
+	// Format names the header version: "miniLock", "fminilock",
+	// "fminilock-binary", "fminilock-framed" or "fminilock-binary-framed", as
+	// listed in headerFormats.
+	Format string
+	// Entries is the number of decryptInfo entries, which is the number of
+	// recipients unless the sender added decoys.
+	Entries int
+}
+
+// Message is the result of decrypting a miniLock file.
+type Message struct {
+	// SenderIdentityID is the identity that signed the message, AnonymousSender
+	// if Verification is Anonymous, or empty if it is Unverified.
+	SenderIdentityID string
+	// SenderID is the box key the file info was encrypted from.
+	SenderID string
+	// ReplyToID is the box key the sender asked for replies to; empty for
+	// anonymous messages.
+	ReplyToID string
+	// RecipientID is the box key the message was decrypted with, which tells
+	// callers of DecryptWithKeys which of their keys it was sent to.
+	RecipientID  string
+	Filename     string
+	Contents     []byte
+	Verification Verification
+	Header       HeaderInfo
+	// Metadata describes the file, or is nil if the sender included none.
+	Metadata *Metadata
+	// Compression is the algorithm the contents were compressed with before
+	// encryption, or empty. Contents are always returned decompressed.
+	Compression string
+	// Padding is the policy the contents were padded under, or empty. Contents
+	// are always returned without padding.
+	Padding string
+}
+
+// Reader returns a reader over the message contents.
+func (m *Message) Reader() io.Reader {
+	return bytes.NewReader(m.Contents)
+}
+
+// Info returns the header metadata that is visible without a key.
+func (hdr *miniLockv1Header) Info() HeaderInfo {
+	return HeaderInfo{Version: hdr.Version, Format: hdr.format().name, Entries: len(hdr.DecryptInfo)}
+}
+
+type decryptConfig struct {
+	limits      Limits
+	allowLegacy bool
+}
+
+// DecryptOption configures DecryptMessage and friends.
+type DecryptOption func(*decryptConfig)
+
+// AllowLegacy decrypts legacy miniLock files, which have no sender identity,
+// reporting them as Unverified instead of failing with ErrLegacyFile.
+func AllowLegacy() DecryptOption {
+	return func(c *decryptConfig) {
+		c.allowLegacy = true
+	}
+}
+
+// WithLimits parses headers within limits rather than DefaultLimits.
+func WithLimits(limits Limits) DecryptOption {
+	return func(c *decryptConfig) {
+		c.limits = limits
+	}
+}
+
+func newDecryptConfig(opts []DecryptOption) *decryptConfig {
+	c := &decryptConfig{limits: DefaultLimits}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// DecryptMessage parses a miniLock file and decrypts it with recipientKey.
+// As with DecryptFileContents, check the error to see if it's benign
+// (ErrCannotDecrypt, the file isn't for this key) or bad.
+func DecryptMessage(fileContents []byte, recipientKey *taber.Keys, opts ...DecryptOption) (*Message, error) {
+	header, ciphertext, err := ParseFileContentsWithLimits(fileContents, newDecryptConfig(opts).limits)
+	if err != nil {
+		return nil, err
+	}
+	return header.DecryptMessage(ciphertext, recipientKey, opts...)
+}
+
+// DecryptMessageWithStrings derives the recipient's key from their email and
+// passphrase, decrypts with it, and wipes the key when finished.
+func DecryptMessageWithStrings(fileContents []byte, recipientEmail, recipientPassphrase string, opts ...DecryptOption) (*Message, error) {
+	recipientKey, err := taber.FromEmailAndPassphrase(recipientEmail, recipientPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer recipientKey.Wipe()
+	return DecryptMessage(fileContents, recipientKey, opts...)
+}
+
+// DecryptMessage uses a miniLock file's header to decrypt its ciphertext with
+// recipientKey.
+func (hdr *miniLockv1Header) DecryptMessage(ciphertext []byte, recipientKey *taber.Keys, opts ...DecryptOption) (*Message, error) {
+	return hdr.DecryptMessageWithKeys(ciphertext, []*taber.Keys{recipientKey}, opts...)
+}
+
+// DecryptMessageWithKeys uses a miniLock file's header to decrypt its
+// ciphertext with whichever of keys it was sent to, trying every key against
+// every entry. If it was sent to more than one, the first in keys is used.
+func (hdr *miniLockv1Header) DecryptMessageWithKeys(ciphertext []byte, keys []*taber.Keys, opts ...DecryptOption) (*Message, error) {
+	config := newDecryptConfig(opts)
+	nonce, DI, recipientKey, err := hdr.extractDecryptInfoWithKeys(keys, config.allowLegacy)
+	if err != nil {
+		return nil, err
+	}
+	FI, err := DI.ExtractFileInfo(nonce, recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	defer FI.Wipe()
+	// The header version isn't authenticated, but the file info is; a
+	// mismatch means the version was changed, or the file is malformed.
+	if FI.Framed != hdr.format().framed {
+		return nil, &HeaderError{ErrFramedPayloadVersion}
+	}
+	msg := &Message{
+		SenderIdentityID: DI.SenderIdentity(),
+		SenderID:         DI.SenderID,
+		ReplyToID:        DI.ReplyToID,
+		RecipientID:      DI.RecipientID,
+		Verification:     Signed,
+		Header:           hdr.Info(),
+	}
+	if DI.Anonymous {
+		msg.Verification = Anonymous
+	} else if DI.isLegacy() {
+		msg.Verification = Unverified
+	}
+	var frame *frameHeader
+	msg.Filename, msg.Contents, frame, err = FI.decryptFile(ciphertext, config.limits)
+	if err != nil {
+		return nil, err
+	}
+	msg.Metadata, msg.Compression, msg.Padding = frame.Metadata, frame.Compression, frame.Padding
+	return msg, nil
+}
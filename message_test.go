@@ -0,0 +1,68 @@
+package minilock
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func Test_DecryptMessage(t *testing.T) {
+	testcase, err := Asset("binary_samples/mye.go.minilock")
+	if err != nil {
+		t.Fatal("Couldn't load test binary asset.")
+	}
+	expectedPlaintext, err := Asset("binary_samples/mye.go")
+	if err != nil {
+		t.Fatal("Couldn't load test binary asset.")
+	}
+	msg, err := DecryptMessageWithStrings(testcase, "cathalgarvey@some.where", "this is a password that totally works for minilock purposes")
+	if err != nil {
+		t.Fatal("Failed to decrypt with recipient: " + err.Error())
+	}
+	if msg.SenderIdentityID != testKey1ID {
+		t.Error("SenderIdentityID was expected to be '", testKey1ID, "' but was: ", msg.SenderIdentityID)
+	}
+	if msg.Verification != Signed {
+		t.Error("Expected a signed message, got: ", msg.Verification)
+	}
+	if msg.SenderID != "Arv5UQQatYC7TvPVNWA6JLduApVMWoYV4f9DS2q5dRbt4" {
+		t.Error("Unexpected SenderID: ", msg.SenderID)
+	}
+	if msg.ReplyToID != "8nqVZubQa5abyNV1RhkW9Un8BcpFNqXGZaKQCe5obdFb6" {
+		t.Error("Unexpected ReplyToID: ", msg.ReplyToID)
+	}
+	if msg.Header.Version != 1 || msg.Header.Entries < 1 {
+		t.Error("Unexpected header info: ", msg.Header)
+	}
+	if msg.Filename != "mye.go" {
+		t.Error("Filename returned should have been 'mye.go', was: " + msg.Filename)
+	}
+	read, err := ioutil.ReadAll(msg.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Contents, expectedPlaintext) || !bytes.Equal(read, expectedPlaintext) {
+		t.Error("Plaintext did not match expected plaintext.")
+	}
+}
+
+func Test_DecryptAnonymousMessage(t *testing.T) {
+	recipient, err := EphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genCrypted, err := EncryptFileContentsAnonymously("tip.txt", []byte("Who sent this?"), recipient.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := DecryptMessage(genCrypted, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Verification != Anonymous || msg.SenderIdentityID != AnonymousSender {
+		t.Error("Anonymous message wasn't reported as such: ", msg.Verification, msg.SenderIdentityID)
+	}
+	if msg.Header.Entries != 1 {
+		t.Error("Expected one decryptInfo entry, got: ", msg.Header.Entries)
+	}
+}
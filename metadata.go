@@ -0,0 +1,41 @@
+package minilock
+
+import (
+	"os"
+	"time"
+)
+
+// Metadata describes an encrypted file beyond its name. It is stored once, in
+// the frame ahead of the contents, so it is encrypted and authenticated along
+// with them and adds nothing to each recipient's entry. All fields are
+// optional, and readers ignore fields they don't know, so more may be added.
+type Metadata struct {
+	// ModTime is the file's modification time.
+	ModTime *time.Time `json:"mtime,omitempty"`
+	// Mode holds the file's permission bits.
+	Mode os.FileMode `json:"mode,omitempty"`
+	// ContentType is the MIME type of the contents.
+	ContentType string `json:"contentType,omitempty"`
+	// Size is the length of the plaintext, which the Encrypter fills in.
+	Size int64 `json:"size,omitempty"`
+	// Extra holds free-form keys and values.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// MetadataFromFileInfo returns the modification time and permissions of a
+// file, as from os.Stat.
+func MetadataFromFileInfo(info os.FileInfo) *Metadata {
+	modTime := info.ModTime()
+	return &Metadata{
+		ModTime: &modTime,
+		Mode:    info.Mode().Perm(),
+		Size:    info.Size(),
+	}
+}
+
+// Copies md with its size set, leaving the caller's copy alone.
+func (md *Metadata) withSize(size int) *Metadata {
+	sized := *md
+	sized.Size = int64(size)
+	return &sized
+}
@@ -0,0 +1,67 @@
+package minilock
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_MetadataRoundTrip(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "minilock-metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	plaintext := []byte("Minutes of the meeting.")
+	tmp.Write(plaintext)
+	tmp.Close()
+	modTime := time.Date(2015, 3, 14, 15, 9, 26, 0, time.UTC)
+	if err = os.Chtimes(tmp.Name(), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chmod(tmp.Name(), 0640); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := MetadataFromFileInfo(info)
+	md.ContentType = "text/plain"
+	md.Extra = map[string]string{"reviewed-by": "legal"}
+	md.Size = 0
+
+	recipient, _ := EphemeralKey()
+	encrypted := mustEncrypt(t, plaintext, WithIdentity(testKey1), WithRecipients(recipient), WithFilename("minutes.txt"), WithMetadata(md))
+	if md.Size != 0 {
+		t.Error("Encrypter changed the caller's metadata")
+	}
+	msg, err := DecryptMessage(encrypted, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := msg.Metadata
+	if got == nil {
+		t.Fatal("No metadata in decrypted message")
+	}
+	if got.ModTime == nil || !got.ModTime.Equal(modTime) {
+		t.Error("Modification time didn't round-trip: ", got.ModTime)
+	}
+	if got.Mode != 0640 || got.ContentType != "text/plain" || got.Size != int64(len(plaintext)) {
+		t.Error("Metadata didn't round-trip: ", got.Mode, got.ContentType, got.Size)
+	}
+	if !reflect.DeepEqual(got.Extra, md.Extra) {
+		t.Error("Extra metadata didn't round-trip: ", got.Extra)
+	}
+
+	// Without WithMetadata, nothing is stored.
+	encrypted, err = EncryptFileContents("minutes.txt", plaintext, recipient, recipient, testKey1, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = DecryptMessage(encrypted, recipient); err != nil || msg.Metadata != nil {
+		t.Error("Expected no metadata, got: ", msg.Metadata, err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+/*
+* minilock-agent: Holds derived miniLock keys in memory so that minilock-cli
+* and other clients can decrypt and sign without re-deriving keys or ever
+* seeing the private key material.
+ */
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/cathalgarvey/go-minilock/agent"
+	"github.com/howeyc/gopass"
+)
+
+var (
+	socket = kingpin.Flag("socket", "Path of the agent socket. Defaults to $"+agent.SocketEnv+", or a per-user socket in $XDG_RUNTIME_DIR or the temp directory.").
+		Short('s').Default(agent.SocketPath()).String()
+
+	serve    = kingpin.Command("serve", "Run the agent in the foreground.")
+	serveTTL = serve.Flag("ttl", "How long to hold keys that are added without an explicit TTL.").Default(agent.DefaultTTL.String()).Duration()
+
+	add      = kingpin.Command("add", "Derive a key from email and passphrase and hand it to the agent.")
+	addEmail = add.Arg("user-email", "Your email address, as used to derive your miniLock key.").Required().String()
+	addTTL   = add.Flag("ttl", "How long the agent should hold this key. Defaults to the agent's TTL.").Duration()
+
+	list = kingpin.Command("list", "List the identities held by the agent.")
+
+	forget   = kingpin.Command("forget", "Wipe a key held by the agent.")
+	forgetID = forget.Arg("identity-id", "Identity ID of the key to forget.").Required().String()
+
+	lock = kingpin.Command("lock", "Wipe every key held by the agent.")
+)
+
+func main() {
+	kingpin.UsageTemplate(kingpin.DefaultUsageTemplate).Author("Cathal Garvey")
+	switch kingpin.Parse() {
+	case "serve":
+		kingpin.FatalIfError(serveAgent(), "Agent failed..")
+	case "add":
+		kingpin.FatalIfError(addKey(), "Failed to add key..")
+	case "list":
+		kingpin.FatalIfError(listKeys(), "Failed to list keys..")
+	case "forget":
+		kingpin.FatalIfError(withClient(func(c *agent.Client) error { return c.Remove(*forgetID) }), "Failed to forget key..")
+	case "lock":
+		kingpin.FatalIfError(withClient(func(c *agent.Client) error { return c.Lock() }), "Failed to lock agent..")
+	}
+}
+
+func serveAgent() error {
+	if err := agent.LockMemory(); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not lock agent memory, keys may be swapped to disk:", err)
+	}
+	l, err := agent.Listen(*socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*socket)
+	a := agent.New(*serveTTL)
+	defer a.Lock()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		l.Close()
+	}()
+	fmt.Println("minilock-agent listening on", *socket)
+	fmt.Println("export " + agent.SocketEnv + "=" + *socket)
+	a.Serve(l)
+	return nil
+}
+
+func withClient(f func(*agent.Client) error) error {
+	c, err := agent.Dial(*socket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return f(c)
+}
+
+func addKey() error {
+	fmt.Print("Enter passphrase: ")
+	pp, err := gopass.GetPasswd()
+	if err != nil {
+		return err
+	}
+	return withClient(func(c *agent.Client) error {
+		id, err := c.Add(*addEmail, string(pp), *addTTL)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Added box ID '" + id.BoxID + "', identity ID '" + id.IdentityID + "', expires " + id.Expires.Format(time.RFC3339))
+		return nil
+	})
+}
+
+func listKeys() error {
+	return withClient(func(c *agent.Client) error {
+		ids, err := c.List()
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			fmt.Println(id.IdentityID, id.BoxID, id.Expires.Format(time.RFC3339))
+		}
+		return nil
+	})
+}
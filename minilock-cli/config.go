@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+var (
+	configFile = kingpin.Flag("config", "Configuration file to read profiles from. Defaults to minilock/config.json in your XDG config directory.").
+			Envar("MINILOCK_CONFIG").String()
+	profileName = kingpin.Flag("profile", "Use this profile from the configuration file, rather than its default profile.").
+			Envar("MINILOCK_PROFILE").String()
+	kdfFlag = kingpin.Flag("kdf", "Cost of hardening passphrases for key files and --symmetric: 'interactive', 'moderate' (the default) or 'sensitive'.").
+		Enum("interactive", "moderate", "sensitive")
+
+	// The profile in use; the zero profile if there is no configuration file.
+	prof = new(profile)
+)
+
+// The configuration file holds named profiles of defaults, for example:
+//
+//	{
+//	  "defaultProfile": "work",
+//	  "profiles": {
+//	    "work": {
+//	      "email": "me@work.example",
+//	      "recipients": ["<miniLock ID of a colleague>"],
+//	      "kdf": "sensitive",
+//	      "outputDir": "~/Encrypted",
+//	      "armor": true,
+//	      "keyring": "~/work/minilock-keys"
+//	    }
+//	  }
+//	}
+//
+// Flags and arguments given on the command line override the profile: boolean
+// settings can be turned off with --no-armor or --no-dont-encrypt-to-self, and
+// --no-profile-recipients leaves out the profile's recipients.
+type config struct {
+	DefaultProfile string              `json:"defaultProfile"`
+	Profiles       map[string]*profile `json:"profiles"`
+}
+
+type profile struct {
+	// Email is used when no user-email argument is given.
+	Email string `json:"email"`
+	// Recipients are miniLock IDs added to every encrypted file.
+	Recipients []string `json:"recipients"`
+	// DontEncryptToSelf is as --dont-encrypt-to-self.
+	DontEncryptToSelf bool `json:"dontEncryptToSelf"`
+	// KDF is as --kdf.
+	KDF string `json:"kdf"`
+	// OutputDir is where encrypted and decrypted files are written unless
+	// --output is given.
+	OutputDir string `json:"outputDir"`
+	// Armor is as --armor.
+	Armor bool `json:"armor"`
+	// Keyring is the directory of key files used by name with --key-file.
+	Keyring string `json:"keyring"`
+}
+
+// The scrypt costs behind --kdf: each step takes eight times the memory and
+// time of the one before.
+var kdfProfiles = map[string]taber.KDFParams{
+	"interactive": {LogN: 14, R: 8, P: 1},
+	"moderate":    taber.DefaultKDFParams,
+	"sensitive":   {LogN: 20, R: 8, P: 1},
+}
+
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "minilock"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "minilock"), nil
+}
+
+// Expands a leading ~ in paths from the configuration file, which no shell
+// has seen.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// Loads the profile named by --profile, or else the configuration file's
+// default profile. A missing configuration file is only an error if a profile
+// was asked for.
+func loadProfile() (*profile, error) {
+	path := *configFile
+	if path == "" {
+		dir, err := configDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "config.json")
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && *profileName == "" {
+		return new(profile), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cfg config
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("Can't read configuration file %s: %v", path, err)
+	}
+	name := *profileName
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		return new(profile), nil
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok || p == nil {
+		names := make([]string, 0, len(cfg.Profiles))
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("No profile '%s' in %s; it has: %s", name, path, strings.Join(names, ", "))
+	}
+	if _, ok = kdfProfiles[p.KDF]; p.KDF != "" && !ok {
+		return nil, fmt.Errorf("Profile '%s' has unknown kdf '%s'", name, p.KDF)
+	}
+	if p.OutputDir, err = expandHome(p.OutputDir); err != nil {
+		return nil, err
+	}
+	if p.Keyring, err = expandHome(p.Keyring); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// The value of a boolean flag if it was given, on or off, or else the
+// profile's.
+func flagOrProfile(flag, set, fromProfile bool) bool {
+	if set {
+		return flag
+	}
+	return fromProfile
+}
+
+// The email to derive keys from: the argument if given, else the profile's.
+func userEmail(arg string) string {
+	if arg != "" {
+		return arg
+	}
+	return prof.Email
+}
+
+// The scrypt parameters chosen by --kdf or the profile.
+func kdfParams() taber.KDFParams {
+	if *kdfFlag != "" {
+		return kdfProfiles[*kdfFlag]
+	}
+	if prof.KDF != "" {
+		return kdfProfiles[prof.KDF]
+	}
+	return taber.DefaultKDFParams
+}
+
+// Where to write a file named name, when --output wasn't given.
+func outputPath(name string) string {
+	if prof.OutputDir == "" {
+		return name
+	}
+	return filepath.Join(prof.OutputDir, filepath.Base(name))
+}
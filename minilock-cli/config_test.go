@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+  "defaultProfile": "work",
+  "profiles": {
+    "work": {"email": "me@work.example", "recipients": ["colleague"], "kdf": "sensitive", "outputDir": "~/Encrypted"},
+    "home": {"email": "me@home.example", "armor": true},
+    "odd": {"kdf": "extreme"}
+  }
+}`
+
+// Points --config and --profile at a configuration file holding contents,
+// restoring them when the test ends.
+func withConfig(t *testing.T, contents, name string) {
+	dir, err := ioutil.TempDir("", "minilock-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if contents != "" {
+		if err = ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldFile, oldName := *configFile, *profileName
+	*configFile, *profileName = path, name
+	t.Cleanup(func() {
+		*configFile, *profileName = oldFile, oldName
+		os.RemoveAll(dir)
+	})
+}
+
+func Test_LoadProfile(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, testConfig, "")
+	p, err := loadProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Email != "me@work.example" || p.KDF != "sensitive" || !reflect.DeepEqual(p.Recipients, []string{"colleague"}) {
+		t.Error("Default profile didn't load: ", p)
+	}
+	if p.OutputDir != filepath.Join(home, "Encrypted") {
+		t.Error("Expected ~ expanded in outputDir, got: ", p.OutputDir)
+	}
+
+	withConfig(t, testConfig, "home")
+	if p, err = loadProfile(); err != nil || p.Email != "me@home.example" || !p.Armor {
+		t.Error("Named profile didn't load: ", p, err)
+	}
+
+	for name, tc := range map[string]struct {
+		contents, profile, err string
+	}{
+		"missing profile":  {testConfig, "play", "No profile 'play'"},
+		"unknown kdf":      {testConfig, "odd", "unknown kdf"},
+		"unknown field":    {`{"profiles": {"work": {"emial": "me@work.example"}}}`, "work", "unknown field"},
+		"no file, profile": {"", "work", "no such file"},
+	} {
+		withConfig(t, tc.contents, tc.profile)
+		if _, err = loadProfile(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Error("Expected an error mentioning ", tc.err, " for ", name, ", got: ", err)
+		}
+	}
+
+	// Without a configuration file or a profile asked for, nothing is set.
+	withConfig(t, "", "")
+	if p, err = loadProfile(); err != nil || !reflect.DeepEqual(p, new(profile)) {
+		t.Error("Expected an empty profile without a configuration file, got: ", p, err)
+	}
+}
+
+func Test_SplitEmailArg(t *testing.T) {
+	const id = "2Ddpk7j3cnyHRUNbukQTEagXFBHSGZV4suemTjEKyZs6BF"
+	oldProf, oldEmail, oldRecipients := prof, *eUserEmail, *recipients
+	defer func() {
+		prof, *eUserEmail, *recipients = oldProf, oldEmail, oldRecipients
+		*keyFile, *noProfileRecipients = "", false
+	}()
+	prof = &profile{Email: "me@work.example", Recipients: []string{"colleague"}}
+	for _, tc := range []struct {
+		name, arg    string
+		keyFile      string
+		noProfile    bool
+		email        string
+		recipientIDs []string
+	}{
+		{"email", "me@home.example", "", false, "me@home.example", []string{"other", "colleague"}},
+		{"miniLock ID", id, "", false, "me@work.example", []string{id, "other", "colleague"}},
+		{"none", "", "", false, "me@work.example", []string{"other", "colleague"}},
+		{"key file", "me@home.example", "keys.mlk", false, "", []string{"me@home.example", "other", "colleague"}},
+		{"no profile recipients", "", "", true, "me@work.example", []string{"other"}},
+	} {
+		*eUserEmail, *recipients = tc.arg, []string{"other"}
+		*keyFile, *noProfileRecipients = tc.keyFile, tc.noProfile
+		email, recipientIDs := splitEmailArg()
+		if email != tc.email || !reflect.DeepEqual(recipientIDs, tc.recipientIDs) {
+			t.Error("For ", tc.name, " expected ", tc.email, " and ", tc.recipientIDs, ", got ", email, " and ", recipientIDs)
+		}
+	}
+}
+
+func Test_FlagOrProfile(t *testing.T) {
+	for _, tc := range []struct {
+		flag, set, fromProfile, want bool
+	}{
+		{false, false, true, true},
+		{false, true, true, false},
+		{true, true, false, true},
+		{false, false, false, false},
+	} {
+		if got := flagOrProfile(tc.flag, tc.set, tc.fromProfile); got != tc.want {
+			t.Error("flagOrProfile(", tc.flag, ", ", tc.set, ", ", tc.fromProfile, ") = ", got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/cathalgarvey/go-minilock"
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+var (
+	idCmd   = kingpin.Command("id", "Print your miniLock box ID, which others encrypt to, and your identity ID, which signs what you send.")
+	idEmail = idCmd.
+		Arg("user-email", "Your email address, as used to derive your miniLock key. Omitted with --key-file.").
+		String()
+	idJSON = idCmd.Flag("json", "Print the IDs as a JSON object, for scripts.").Bool()
+)
+
+type printedIDs struct {
+	Email      string `json:"email,omitempty"`
+	BoxID      string `json:"boxID,omitempty"`
+	IdentityID string `json:"identityID,omitempty"`
+}
+
+func printIDs() error {
+	var (
+		keys     *taber.Keys
+		identity *minilock.IdentityKeys
+		ids      = printedIDs{}
+		err      error
+	)
+	if *keyFile != "" {
+		keys, identity, err = loadKeyFile(*keyFile)
+	} else {
+		ids.Email = userEmail(*idEmail)
+		if ids.Email == "" {
+			return fmt.Errorf("user-email is required unless --key-file is given or your profile has one")
+		}
+		var pp string
+		if pp, err = getPass(); err != nil {
+			return err
+		}
+		keys, identity, err = minilock.GenerateKeys(ids.Email, pp)
+	}
+	if err != nil {
+		return err
+	}
+	if keys != nil {
+		defer keys.Wipe()
+		if ids.BoxID, err = keys.EncodeID(); err != nil {
+			return err
+		}
+	}
+	if identity != nil {
+		defer identity.Wipe()
+		if ids.IdentityID, err = identity.EncodeID(); err != nil {
+			return err
+		}
+	}
+	if *idJSON {
+		return json.NewEncoder(os.Stdout).Encode(ids)
+	}
+	return printKeyIDs(keys, identity)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/cathalgarvey/go-minilock"
+)
+
+var (
+	infoCmd   = kingpin.Command("info", "Decrypt a file and describe it, without saving its contents.")
+	infoFile  = infoCmd.Arg("file", "File to describe.").Required().String()
+	infoEmail = infoCmd.
+			Arg("user-email", "Your email address, as used to derive your miniLock key. Omitted with --agent, --key-file or --symmetric.").
+			String()
+	infoUseAgent  = infoCmd.Flag("agent", "Decrypt using keys held by a running minilock-agent instead of deriving them here.").Bool()
+	infoSymmetric = infoCmd.Flag("symmetric", "Decrypt with the passphrase the file was encrypted with using --symmetric.").Bool()
+	infoLegacy    = infoCmd.Flag("legacy", "Accept files from miniLock v1 clients, which carry no sender identity. Not available with --agent.").Bool()
+)
+
+func printInfo() error {
+	fileContents, err := readMiniLockFile(*infoFile)
+	if err != nil {
+		return err
+	}
+	msg, err := decryptMessage(fileContents, userEmail(*infoEmail), *infoUseAgent, *infoSymmetric, *infoLegacy)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Header:        %s (version %d), %d entries\n", msg.Header.Format, msg.Header.Version, msg.Header.Entries)
+	switch msg.Verification {
+	case minilock.Signed:
+		fmt.Println("Sender:       ", msg.SenderIdentityID, "(signature verified)")
+	default:
+		fmt.Println("Sender:       ", msg.Verification)
+	}
+	fmt.Println("Recipient:    ", msg.RecipientID)
+	if msg.ReplyToID != "" {
+		fmt.Println("Reply to:     ", msg.ReplyToID)
+	}
+	fmt.Printf("Filename:      %q\n", msg.Filename)
+	fmt.Println("Size:         ", len(msg.Contents), "bytes")
+	if msg.Compression != "" {
+		fmt.Println("Compression:  ", msg.Compression)
+	}
+	if msg.Padding != "" {
+		fmt.Println("Padding:      ", msg.Padding)
+	}
+	md := msg.Metadata
+	if md == nil {
+		fmt.Println("Metadata:      none")
+		return nil
+	}
+	if md.ModTime != nil {
+		fmt.Println("Modified:     ", md.ModTime.Format(time.RFC3339))
+	}
+	if md.Mode != 0 {
+		fmt.Println("Permissions:  ", md.Mode)
+	}
+	if md.ContentType != "" {
+		fmt.Println("Content type: ", md.ContentType)
+	}
+	keys := make([]string, 0, len(md.Extra))
+	for k := range md.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) != 0 {
+		fmt.Println("Extra:")
+	}
+	for _, k := range keys {
+		fmt.Printf("  %s: %s\n", k, md.Extra[k])
+	}
+	return nil
+}
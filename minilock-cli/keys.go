@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/cathalgarvey/go-minilock"
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+var (
+	keyFile = kingpin.Flag("key-file", "Use the keys in this key file, or the key of this name in your keyring, instead of deriving them from your email and passphrase. The user-email argument must then be omitted.").
+		Short('k').String()
+
+	keygen            = kingpin.Command("keygen", "Generate a random box key and identity and save them to a key file.")
+	keygenOut         = keygen.Arg("key-file", "Where to write the new key file.").Required().String()
+	keygenUnencrypted = keygen.Flag("unencrypted", "Store the private keys unencrypted, for servers where the key file is otherwise protected.").Bool()
+
+	exportKey      = kingpin.Command("export-key", "Derive your keys from email and passphrase and save them to a key file, encrypted with the same passphrase.")
+	exportKeyOut   = exportKey.Arg("key-file", "Where to write the key file.").Required().String()
+	exportKeyEmail = exportKey.
+			Arg("user-email", "Your email address, as used to derive your miniLock key. Taken from your profile if omitted.").
+			String()
+	exportKeyUnencrypted = exportKey.Flag("unencrypted", "Store the private keys unencrypted, for servers where the key file is otherwise protected.").Bool()
+
+	importKey     = kingpin.Command("import-key", "Check a key file and add it to your keyring, so that it can be used by name with --key-file.")
+	importKeyPath = importKey.Arg("key-file", "Key file to import.").Required().String()
+	importKeyName = importKey.Flag("name", "Name to store the key under in the keyring. Defaults to the key file's name without extension.").String()
+)
+
+// Directory holding imported key files, each named <name>.key.
+func keyringDir() (string, error) {
+	if prof.Keyring != "" {
+		return prof.Keyring, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "minilock", "keys"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "minilock", "keys"), nil
+}
+
+// Resolve a --key-file argument: a path if one exists, otherwise a name in the keyring.
+func keyFilePath(name string) (string, error) {
+	if _, err := os.Stat(name); err == nil || strings.ContainsRune(name, filepath.Separator) {
+		return name, nil
+	}
+	dir, err := keyringDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".key"), nil
+}
+
+// Load keys from a key file, asking for its passphrase only if it is encrypted.
+func loadKeyFile(name string) (*taber.Keys, *minilock.IdentityKeys, error) {
+	path, err := keyFilePath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, identity, err := minilock.ImportKeys(encoded, "")
+	if err != minilock.ErrKeyFileLocked {
+		return keys, identity, err
+	}
+	pp, err := getPass()
+	if err != nil {
+		return nil, nil, err
+	}
+	return minilock.ImportKeys(encoded, pp)
+}
+
+func printKeyIDs(keys *taber.Keys, identity *minilock.IdentityKeys) error {
+	if keys != nil {
+		id, err := keys.EncodeID()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Box ID:      " + id)
+	}
+	if identity != nil {
+		id, err := identity.EncodeID()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Identity ID: " + id)
+	}
+	return nil
+}
+
+func writeKeyFile(path string, keys *taber.Keys, identity *minilock.IdentityKeys, passphrase string) error {
+	encoded, err := minilock.ExportKeysWithParams(keys, identity, passphrase, kdfParams())
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, encoded, 0600); err != nil {
+		return err
+	}
+	fmt.Println("Keys written to", path)
+	return printKeyIDs(keys, identity)
+}
+
+func generateKeyFile() error {
+	var (
+		pp  string
+		err error
+	)
+	if !*keygenUnencrypted {
+		if pp, err = getPass(); err != nil {
+			return err
+		}
+	}
+	keys, err := minilock.EphemeralKey()
+	if err != nil {
+		return err
+	}
+	defer keys.Wipe()
+	identity, err := minilock.RandomIdentity()
+	if err != nil {
+		return err
+	}
+	defer identity.Wipe()
+	return writeKeyFile(*keygenOut, keys, identity, pp)
+}
+
+func exportKeyFile() error {
+	email := userEmail(*exportKeyEmail)
+	if email == "" {
+		return fmt.Errorf("user-email is required unless your profile has one")
+	}
+	pp, err := getPass()
+	if err != nil {
+		return err
+	}
+	keys, identity, err := minilock.GenerateKeys(email, pp)
+	if err != nil {
+		return err
+	}
+	defer keys.Wipe()
+	defer identity.Wipe()
+	if *exportKeyUnencrypted {
+		pp = ""
+	}
+	return writeKeyFile(*exportKeyOut, keys, identity, pp)
+}
+
+func importKeyFile() error {
+	encoded, err := ioutil.ReadFile(*importKeyPath)
+	if err != nil {
+		return err
+	}
+	// Check the file can actually be opened before adding it to the keyring.
+	keys, identity, err := loadKeyFile(*importKeyPath)
+	if err != nil {
+		return err
+	}
+	if keys != nil {
+		defer keys.Wipe()
+	}
+	if identity != nil {
+		defer identity.Wipe()
+	}
+	name := *importKeyName
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(*importKeyPath), filepath.Ext(*importKeyPath))
+	}
+	dir, err := keyringDir()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name+".key")
+	if _, err = os.Stat(path); err == nil {
+		return fmt.Errorf("A key named '%s' is already in the keyring", name)
+	}
+	if err = ioutil.WriteFile(path, encoded, 0600); err != nil {
+		return err
+	}
+	fmt.Println("Imported key '" + name + "' to " + path)
+	return printKeyIDs(keys, identity)
+}
@@ -8,19 +8,21 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/cathalgarvey/go-minilock"
+	"github.com/cathalgarvey/go-minilock/agent"
 	"github.com/cathalgarvey/go-minilock/taber"
-	"github.com/howeyc/gopass"
 )
 
 var (
 	encrypt = kingpin.Command("encrypt", "Encrypt a file.")
 	decrypt = kingpin.Command("decrypt", "Decrypt a file.")
 
-	passPhrase = kingpin.Flag("passphrase", "Full passphrase for this miniLock key. If not given through this flag, it will be asked for interactively").
-			Short('p').String()
 	outputFilename = kingpin.Flag("output", "Name of output file. By default for encryption, this is input filename + '.minilock', and for decryption this is the indicated filename in the ciphertext. Warning: Right now this presents potential security hazards!").
 			Short('o').Default("NOTGIVEN").String()
 
@@ -28,14 +30,29 @@ var (
 	dfile = decrypt.Arg("file", "File to encrypt or decrypt.").Required().String()
 
 	eUserEmail = encrypt.
-			Arg("user-email", "Your email address. This need not be secret, but if this isn't *accurate* it must be *globally unique*, it is used for generating security.").
-			Required().String()
+			Arg("user-email", "Your email address. This need not be secret, but if this isn't *accurate* it must be *globally unique*, it is used for generating security. Omitted with --key-file, or taken from your profile; if it is a miniLock ID, it is treated as a recipient.").
+			String()
 	dUserEmail = decrypt.
-			Arg("user-email", "Your email address. This need not be secret, but if this isn't *accurate* it must be *globally unique*, it is used for generating security.").
-			Required().String()
+			Arg("user-email", "Your email address. This need not be secret, but if this isn't *accurate* it must be *globally unique*, it is used for generating security. Omitted with --agent, --key-file or --symmetric, or taken from your profile.").
+			String()
+	dUseAgent = decrypt.Flag("agent", "Decrypt using keys held by a running minilock-agent instead of deriving them here.").Bool()
+	dLegacy   = decrypt.Flag("legacy", "Accept files from miniLock v1 clients, which carry no sender identity. The sender of such files is unauthenticated. Not available with --agent.").Bool()
 
-	recipients      = encrypt.Arg("recipients", "One or more miniLock IDs to add to encrypted file.").Strings()
-	noEncryptToSelf = encrypt.Flag("dont-encrypt-to-self", "Normal behaviour is to add sender's key to recipients list; this disables that action.").Bool()
+	recipients          = encrypt.Arg("recipients", "One or more miniLock IDs to add to encrypted file.").Strings()
+	noEncryptToSelf     = encrypt.Flag("dont-encrypt-to-self", "Normal behaviour is to add sender's key to recipients list; this disables that action. --no-dont-encrypt-to-self overrides a profile that sets it.").Action(markSet(&noEncryptToSelfSet)).Bool()
+	noEncryptToSelfSet  bool
+	noProfileRecipients = encrypt.Flag("no-profile-recipients", "Don't add the recipients listed in your profile to this file.").Bool()
+	eAnonymous          = encrypt.Flag("anonymous", "Send without any sender identity, so that not even recipients know who sent the file. No user-email or key is needed; if one is given it is treated as a recipient.").Bool()
+	eNoMetadata         = encrypt.Flag("no-metadata", "Don't record the file's modification time, permissions and content type.").Bool()
+	eMeta               = encrypt.Flag("meta", "Extra metadata to record, as key=value. May be repeated.").StringMap()
+	eCompress           = encrypt.Flag("compress", "Compress the file with gzip before encrypting it. Off by default (--no-compress): compression can leak the contents of files that mix your secrets with text others supply, such as chat or form input.").Bool()
+	ePad                = encrypt.Flag("pad", "Pad the file so its size reveals less about its contents: 'padme' adds at most 12%, 'block:N' pads to a multiple of N bytes.").String()
+	eSymmetric          = encrypt.Flag("symmetric", "Also let the file be decrypted with a passphrase, asked for separately, for recipients with no miniLock ID. With --anonymous, no recipients or user-email are needed.").Bool()
+	symmetricPassEnv    = encrypt.Flag("symmetric-passphrase-env", "Read the --symmetric file passphrase from this environment variable, which is then unset, instead of asking for it.").String()
+	dSymmetric          = decrypt.Flag("symmetric", "Decrypt with the passphrase the file was encrypted with using --symmetric, rather than a miniLock key.").Bool()
+	eArmor              = encrypt.Flag("armor", "Write the encrypted file as ASCII text, to <file>.minilock.asc, for pasting into email or chat. Armored files are detected automatically when decrypting.").Action(markSet(&eArmorSet)).Bool()
+	eArmorSet           bool
+	dNoRestore          = decrypt.Flag("no-restore", "Don't restore the modification time and permissions recorded by the sender. Permissions are only ever narrowed.").Bool()
 
 	mlfilecontents []byte
 	userKey        *taber.Keys
@@ -45,14 +62,30 @@ var (
 func main() {
 	kingpin.UsageTemplate(kingpin.DefaultUsageTemplate).Author("Cathal Garvey")
 	//kingpin.CommandLine.Help = "miniLock-cli: The miniLock encryption system for terminal/scripted use."
-	switch kingpin.Parse() {
+	command := kingpin.Parse()
+	prof, err = loadProfile()
+	kingpin.FatalIfError(err, "Failed to read configuration..")
+	switch command {
 	case "encrypt":
-		fmt.Println("Encrypting to self: ", !*noEncryptToSelf)
 		kingpin.FatalIfError(encryptFile(), "Failed to encrypt..")
 	case "decrypt":
 		{
 			kingpin.FatalIfError(decryptFile(), "Failed to decrypt..")
 		}
+	case "sign":
+		kingpin.FatalIfError(signFileCmd(), "Failed to sign..")
+	case "verify-sig":
+		kingpin.FatalIfError(verifySigCmd(), "Verification failed..")
+	case "id":
+		kingpin.FatalIfError(printIDs(), "Failed to derive IDs..")
+	case "keygen":
+		kingpin.FatalIfError(generateKeyFile(), "Failed to generate key file..")
+	case "export-key":
+		kingpin.FatalIfError(exportKeyFile(), "Failed to export key..")
+	case "import-key":
+		kingpin.FatalIfError(importKeyFile(), "Failed to import key..")
+	case "info":
+		kingpin.FatalIfError(printInfo(), "Failed to read file info..")
 	default:
 		{
 			fmt.Println("No subcommand provided..")
@@ -63,60 +96,305 @@ func main() {
 	}
 }
 
+// Returns an action recording that a flag was given, on or off, so that only
+// flags left out are taken from the profile.
+func markSet(set *bool) kingpin.Action {
+	return func(*kingpin.ParseContext) error {
+		*set = true
+		return nil
+	}
+}
+
 func encryptFile() error {
 	f, err := ioutil.ReadFile(*efile)
 	if err != nil {
 		return err
 	}
-	pp := getPass()
-	mlfilecontents, err = minilock.EncryptFileContentsWithStrings(*efile, f, *eUserEmail, pp, !*noEncryptToSelf, *recipients...)
+	email, recipientIDs := splitEmailArg()
+	if *eAnonymous {
+		return encryptFileAnonymously(f, recipientIDs)
+	}
+	if *keyFile != "" {
+		return encryptFileWithKeyFile(f, recipientIDs)
+	}
+	if email == "" {
+		return fmt.Errorf("user-email is required unless --key-file is given or your profile has one")
+	}
+	pp, err := getPass()
 	if err != nil {
 		return err
 	}
-	if *outputFilename == "NOTGIVEN" {
-		*outputFilename = *efile + ".minilock"
+	keys, identity, err := minilock.GenerateKeys(email, pp)
+	if err != nil {
+		return err
 	}
-	userKey, err = minilock.GenerateKey(*eUserEmail, pp)
+	userKey = keys
+	defer identity.Wipe()
+	return encryptWithIdentity(f, identity, minilock.WithRecipientIDs(recipientIDs...))
+}
+
+// Sorts out the user-email argument, which may be left out: there is none
+// with a key file or when sending anonymously, and a miniLock ID is never an
+// email, so in those cases it's the first recipient. Without one, the
+// profile's email is used. The profile's recipients are added to the rest
+// unless --no-profile-recipients is given.
+func splitEmailArg() (email string, recipientIDs []string) {
+	recipientIDs = *recipients
+	if arg := *eUserEmail; arg != "" {
+		if _, err := taber.FromID(arg); err == nil || *keyFile != "" || *eAnonymous {
+			recipientIDs = append([]string{arg}, recipientIDs...)
+		} else {
+			email = arg
+		}
+	}
+	if email == "" && *keyFile == "" && !*eAnonymous {
+		email = prof.Email
+	}
+	if *noProfileRecipients {
+		return email, recipientIDs
+	}
+	return email, append(recipientIDs[:len(recipientIDs):len(recipientIDs)], prof.Recipients...)
+}
+
+func encryptFileWithKeyFile(f []byte, recipientIDs []string) error {
+	keys, identity, err := loadKeyFile(*keyFile)
 	if err != nil {
 		return err
 	}
+	if identity == nil {
+		return fmt.Errorf("Key file has no identity key to sign with")
+	}
+	defer identity.Wipe()
+	userKey = keys
+	return encryptWithIdentity(f, identity, minilock.WithRecipientIDs(recipientIDs...))
+}
+
+// Encrypts f signed by identity, and to userKey (if there is one) as well as
+// the recipients unless told not to.
+func encryptWithIdentity(f []byte, identity *minilock.IdentityKeys, opts ...minilock.Option) error {
+	opts = append(opts, minilock.WithFilename(*efile), minilock.WithIdentity(identity))
+	noSelf := flagOrProfile(*noEncryptToSelf, noEncryptToSelfSet, prof.DontEncryptToSelf)
+	if noSelf || userKey == nil {
+		opts = append(opts, minilock.WithoutSelf())
+	} else {
+		opts = append(opts, minilock.WithSelf(userKey))
+	}
+	if err := encryptWith(f, opts...); err != nil {
+		return err
+	}
+	identityID, err := identity.EncodeID()
+	if err != nil {
+		return err
+	}
+	fmt.Println("File encrypted using identity: '" + identityID + "'")
+	if userKey == nil || noSelf {
+		fmt.Println("Not encrypted to self")
+		return nil
+	}
 	userID, err := userKey.EncodeID()
 	if err != nil {
 		return err
 	}
-	fmt.Println("File encrypted using ID: '" + userID + "'")
-	return ioutil.WriteFile(*outputFilename, mlfilecontents, 33204)
+	fmt.Println("Encrypted to self as ID: '" + userID + "'")
+	return nil
 }
 
-func decryptFile() error {
-	pp := getPass()
-	userKey, err = minilock.GenerateKey(*dUserEmail, pp)
+func encryptFileAnonymously(f []byte, recipientIDs []string) error {
+	if len(recipientIDs) == 0 && !*eSymmetric {
+		return fmt.Errorf("At least one recipient is required, or --symmetric")
+	}
+	err := encryptWith(f, minilock.WithFilename(*efile), minilock.WithAnonymous(), minilock.WithRecipientIDs(recipientIDs...))
+	if err != nil {
+		return err
+	}
+	fmt.Println("File encrypted anonymously")
+	return nil
+}
+
+// Encrypts f with an Encrypter configured by opts and writes the output file.
+func encryptWith(f []byte, opts ...minilock.Option) error {
+	md, err := fileMetadata(f)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		opts = append(opts, minilock.WithMetadata(md))
+	}
+	if *eCompress {
+		opts = append(opts, minilock.WithCompression(minilock.CompressionGzip))
+	}
+	if *ePad != "" {
+		opts = append(opts, minilock.WithPadding(*ePad))
+	}
+	if *eSymmetric {
+		pp, err := getFilePass()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, minilock.WithPassphraseRecipientParams(pp, kdfParams()))
+	}
+	e, err := minilock.NewEncrypter(opts...)
+	if err != nil {
+		return err
+	}
+	defer e.Wipe()
+	var replyTo *taber.Keys
+	mlfilecontents, replyTo, err = e.Encrypt(f)
 	if err != nil {
 		return err
 	}
-	mlfilecontents, err = ioutil.ReadFile(*dfile)
+	if replyTo != nil {
+		replyTo.Wipe()
+	}
+	armor := flagOrProfile(*eArmor, eArmorSet, prof.Armor)
+	if *outputFilename == "NOTGIVEN" {
+		*outputFilename = outputPath(*efile + ".minilock")
+		if armor {
+			*outputFilename += ".asc"
+		}
+	}
+	if armor {
+		mlfilecontents = minilock.Armor(mlfilecontents)
+	}
+	return ioutil.WriteFile(*outputFilename, mlfilecontents, 33204)
+}
+
+// Describes the file being encrypted, unless told not to.
+func fileMetadata(f []byte) (*minilock.Metadata, error) {
+	if *eNoMetadata {
+		if len(*eMeta) != 0 {
+			return nil, fmt.Errorf("--meta can't be used with --no-metadata")
+		}
+		return nil, nil
+	}
+	info, err := os.Stat(*efile)
+	if err != nil {
+		return nil, err
+	}
+	md := minilock.MetadataFromFileInfo(info)
+	md.ContentType = mime.TypeByExtension(filepath.Ext(*efile))
+	if md.ContentType == "" {
+		md.ContentType = http.DetectContentType(f)
+	}
+	if len(*eMeta) != 0 {
+		md.Extra = *eMeta
+	}
+	return md, nil
+}
+
+func decryptFile() error {
+	mlfilecontents, err = readMiniLockFile(*dfile)
 	if err != nil {
 		return err
 	}
-	sender, filename, filecontents, err := minilock.DecryptFileContents(mlfilecontents, userKey)
+	msg, err := decryptMessage(mlfilecontents, userEmail(*dUserEmail), *dUseAgent, *dSymmetric, *dLegacy)
 	if err != nil {
 		return err
 	}
+	filename := msg.Filename
 	if *outputFilename != "NOTGIVEN" {
 		filename = *outputFilename
+	} else if filename != "" {
+		filename = outputPath(filename)
+	}
+	if filename == "" {
+		return fmt.Errorf("File has no name; give one with --output")
+	}
+	switch msg.Verification {
+	case minilock.Anonymous:
+		fmt.Println("File received anonymously: the sender is unknown and unauthenticated. Saving to", filename)
+	case minilock.Unverified:
+		fmt.Println("Legacy file received: the sender is unverified. Saving to", filename)
+	default:
+		fmt.Println("File received from identity '"+msg.SenderIdentityID+"', saving to", filename)
+	}
+	if err = ioutil.WriteFile(filename, msg.Contents, 33204); err != nil {
+		return err
 	}
-	fmt.Println("File received from id '"+sender+"', saving to", filename)
-	return ioutil.WriteFile(filename, filecontents, 33204)
+	if *dNoRestore || msg.Metadata == nil {
+		return nil
+	}
+	return restoreMetadata(filename, msg.Metadata)
 }
 
-func getPass() string {
-	if *passPhrase != "" {
-		return *passPhrase
+// Reads a miniLock file, decoding it if armored.
+func readMiniLockFile(path string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil || !minilock.IsArmored(contents) {
+		return contents, err
 	}
-	fmt.Print("Enter passphrase: ")
-	p, err := gopass.GetPasswd()
-	if err != nil {
-		panic(err)
+	return minilock.Dearmor(contents)
+}
+
+// Decrypts a file with the agent, a passphrase, a key file, or keys derived
+// from email.
+func decryptMessage(fileContents []byte, email string, useAgent, symmetric, legacy bool) (*minilock.Message, error) {
+	if useAgent && legacy {
+		// The agent decrypts with its own options, which never accept legacy files.
+		return nil, fmt.Errorf("--legacy can't be used with --agent")
+	}
+	var opts []minilock.DecryptOption
+	if legacy {
+		opts = append(opts, minilock.AllowLegacy())
+	}
+	if symmetric {
+		pp, err := getPass()
+		if err != nil {
+			return nil, err
+		}
+		return minilock.DecryptWithPassphrase(fileContents, pp, opts...)
+	}
+	if useAgent {
+		c, err := agent.Dial(agent.SocketPath())
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		return c.DecryptMessage(fileContents)
+	}
+	if *keyFile != "" {
+		var identity *minilock.IdentityKeys
+		userKey, identity, err = loadKeyFile(*keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			identity.Wipe()
+		}
+		if userKey == nil {
+			return nil, fmt.Errorf("Key file has no box key to decrypt with")
+		}
+	} else {
+		if email == "" {
+			return nil, fmt.Errorf("user-email is required unless --agent, --key-file or --symmetric is given or your profile has one")
+		}
+		pp, err := getPass()
+		if err != nil {
+			return nil, err
+		}
+		userKey, err = minilock.GenerateKey(email, pp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return minilock.DecryptMessage(fileContents, userKey, opts...)
+}
+
+// Applies the permissions and modification time recorded by the sender. The
+// sender chooses the permissions, so they may only take access away from what
+// the file was created with, never make it writable or executable by others.
+func restoreMetadata(filename string, md *minilock.Metadata) error {
+	if md.Mode != 0 {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err = os.Chmod(filename, info.Mode().Perm()&md.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	if md.ModTime != nil {
+		return os.Chtimes(filename, *md.ModTime, *md.ModTime)
 	}
-	return string(p)
+	return nil
 }
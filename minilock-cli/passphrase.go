@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/howeyc/gopass"
+)
+
+// Non-interactive passphrase sources. When more than one is given, the first
+// in this order wins: --passphrase-fd, --passphrase-file, --passphrase-env,
+// --passphrase-cmd, --passphrase. With none given, the passphrase is asked for
+// interactively.
+var (
+	passPhrase = kingpin.Flag("passphrase", "Full passphrase for this miniLock key. Visible in process listings and shell history; prefer the other --passphrase-* sources for scripting.").
+			Short('p').String()
+	passPhraseFD = kingpin.Flag("passphrase-fd", "Read the passphrase from the first line of this open file descriptor. Takes precedence over all other passphrase sources.").
+			Default("-1").Int()
+	passPhraseFile = kingpin.Flag("passphrase-file", "Read the passphrase from the first line of this file, which should only be readable by you. Takes precedence over --passphrase-env, --passphrase-cmd and --passphrase.").
+			String()
+	passPhraseEnv = kingpin.Flag("passphrase-env", "Read the passphrase from this environment variable, which is then unset. Takes precedence over --passphrase-cmd and --passphrase.").
+			String()
+	passPhraseCmd = kingpin.Flag("passphrase-cmd", "Read the passphrase from the first line of output of this shell command, e.g. a password manager. Takes precedence over --passphrase.").
+			String()
+)
+
+var errEmptyPassphrase = errors.New("Passphrase source provided an empty passphrase")
+
+// Asks for the passphrase to encrypt a file with, separately from the one for
+// the user's key, twice to catch typos, unless symmetricPassEnv names a source.
+func getFilePass() (string, error) {
+	if *symmetricPassEnv != "" {
+		pp, ok := os.LookupEnv(*symmetricPassEnv)
+		if !ok {
+			return "", fmt.Errorf("Environment variable %s is not set", *symmetricPassEnv)
+		}
+		os.Unsetenv(*symmetricPassEnv)
+		if pp == "" {
+			return "", errEmptyPassphrase
+		}
+		return pp, nil
+	}
+	fmt.Print("Enter file passphrase: ")
+	p, err := gopass.GetPasswd()
+	if err != nil {
+		return "", err
+	}
+	fmt.Print("Repeat file passphrase: ")
+	again, err := gopass.GetPasswd()
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(p, again) {
+		return "", errors.New("Passphrases didn't match")
+	}
+	if len(p) == 0 {
+		return "", errEmptyPassphrase
+	}
+	return string(p), nil
+}
+
+func getPass() (string, error) {
+	var (
+		pp  string
+		err error
+	)
+	switch {
+	case *passPhraseFD >= 0:
+		f := os.NewFile(uintptr(*passPhraseFD), "passphrase-fd")
+		if f == nil {
+			return "", fmt.Errorf("Invalid passphrase file descriptor %d", *passPhraseFD)
+		}
+		pp, err = firstLine(f)
+		f.Close()
+	case *passPhraseFile != "":
+		pp, err = passphraseFromFile(*passPhraseFile)
+	case *passPhraseEnv != "":
+		var ok bool
+		pp, ok = os.LookupEnv(*passPhraseEnv)
+		if !ok {
+			return "", fmt.Errorf("Environment variable %s is not set", *passPhraseEnv)
+		}
+		// Don't hand the passphrase down to any child processes.
+		os.Unsetenv(*passPhraseEnv)
+	case *passPhraseCmd != "":
+		pp, err = passphraseFromCommand(*passPhraseCmd)
+	case *passPhrase != "":
+		return *passPhrase, nil
+	default:
+		fmt.Print("Enter passphrase: ")
+		p, err := gopass.GetPasswd()
+		if err != nil {
+			return "", err
+		}
+		return string(p), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if pp == "" {
+		return "", errEmptyPassphrase
+	}
+	return pp, nil
+}
+
+// Reads up to the first newline, dropping it and any carriage return, so
+// that files written by editors or `echo` work as expected.
+func firstLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(bytes.TrimRight(line, "\r\n")), nil
+}
+
+func passphraseFromFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		fmt.Fprintln(os.Stderr, "Warning: passphrase file", path, "is accessible by other users")
+	}
+	return firstLine(f)
+}
+
+func passphraseFromCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Passphrase command failed: %v", err)
+	}
+	return firstLine(bytes.NewReader(out))
+}
@@ -0,0 +1,113 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// The passphrase sources to set up for a getPass test; empty ones are left
+// out.
+type passSources struct {
+	fd, file, env, cmd, flag string
+}
+
+// Sets the --passphrase-* flags to sources, restoring them when the test
+// ends.
+func withPassSources(t *testing.T, dir string, sources passSources) {
+	oldFD, oldFile, oldEnv, oldCmd, oldFlag := *passPhraseFD, *passPhraseFile, *passPhraseEnv, *passPhraseCmd, *passPhrase
+	t.Cleanup(func() {
+		*passPhraseFD, *passPhraseFile, *passPhraseEnv, *passPhraseCmd, *passPhrase = oldFD, oldFile, oldEnv, oldCmd, oldFlag
+	})
+	*passPhraseFD, *passPhraseFile, *passPhraseEnv, *passPhraseCmd, *passPhrase = -1, "", "", "", sources.flag
+	if sources.fd != "" {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(sources.fd)
+		w.Close()
+		// getPass closes the descriptor it is given, so give it a copy.
+		fd, err := syscall.Dup(int(r.Fd()))
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		*passPhraseFD = fd
+	}
+	if sources.file != "" {
+		path := filepath.Join(dir, "passphrase")
+		if err := ioutil.WriteFile(path, []byte(sources.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		*passPhraseFile = path
+	}
+	if sources.env != "" {
+		*passPhraseEnv = "MINILOCK_TEST_PASSPHRASE"
+		os.Setenv(*passPhraseEnv, sources.env)
+		t.Cleanup(func() { os.Unsetenv("MINILOCK_TEST_PASSPHRASE") })
+	}
+	if sources.cmd != "" {
+		*passPhraseCmd = "printf '" + sources.cmd + "'"
+	}
+}
+
+func Test_GetPass(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minilock-passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	all := passSources{"from fd\n", "from file\n", "from env", `from cmd\n`, "from flag"}
+	for _, tc := range []struct {
+		name    string
+		sources passSources
+		want    string
+	}{
+		{"fd", passSources{fd: "from fd\r\nsecond line\n"}, "from fd"},
+		{"file", passSources{file: "from file\nsecond line\n"}, "from file"},
+		{"file without newline", passSources{file: "from file"}, "from file"},
+		{"env", passSources{env: "from env"}, "from env"},
+		{"cmd", passSources{cmd: `from cmd\nsecond line\n`}, "from cmd"},
+		{"flag", passSources{flag: "from flag"}, "from flag"},
+		{"fd first", all, "from fd"},
+		{"then file", passSources{"", all.file, all.env, all.cmd, all.flag}, "from file"},
+		{"then env", passSources{"", "", all.env, all.cmd, all.flag}, "from env"},
+		{"then cmd", passSources{"", "", "", all.cmd, all.flag}, "from cmd"},
+	} {
+		withPassSources(t, dir, tc.sources)
+		pp, err := getPass()
+		if err != nil || pp != tc.want {
+			t.Error("Expected ", tc.want, " for ", tc.name, ", got ", pp, ": ", err)
+		}
+		if tc.sources.env != "" && tc.want == "from env" {
+			if _, set := os.LookupEnv("MINILOCK_TEST_PASSPHRASE"); set {
+				t.Error("Passphrase environment variable wasn't unset for ", tc.name)
+			}
+		}
+	}
+
+	for name, sources := range map[string]passSources{
+		"empty file":    {file: "\n"},
+		"empty command": {cmd: `\n`},
+	} {
+		withPassSources(t, dir, sources)
+		if _, err = getPass(); err != errEmptyPassphrase {
+			t.Error("Expected errEmptyPassphrase for ", name, ", got: ", err)
+		}
+	}
+	withPassSources(t, dir, passSources{})
+	*passPhraseEnv = "MINILOCK_TEST_UNSET_PASSPHRASE"
+	if _, err = getPass(); err == nil {
+		t.Error("Expected an error for an unset passphrase variable")
+	}
+	withPassSources(t, dir, passSources{})
+	*passPhraseCmd = "exit 1"
+	if _, err = getPass(); err == nil {
+		t.Error("Expected an error for a failing passphrase command")
+	}
+}
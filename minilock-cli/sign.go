@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/cathalgarvey/go-minilock"
+)
+
+var (
+	signCmd   = kingpin.Command("sign", "Sign a file with your identity, without encrypting it.")
+	signFile  = signCmd.Arg("file", "File to sign.").Required().String()
+	signEmail = signCmd.
+			Arg("user-email", "Your email address, as used to derive your miniLock key. Omitted with --key-file.").
+			String()
+	signClear = signCmd.Flag("clearsign", "Write a readable document holding both the text and its signature, instead of a detached signature.").Bool()
+
+	verifyCmd    = kingpin.Command("verify-sig", "Verify a signed file. Clearsigned files are detected automatically; otherwise the signature is read from <file>.sig unless given.")
+	verifyFile   = verifyCmd.Arg("file", "Signed file, or clearsigned document.").Required().String()
+	verifySig    = verifyCmd.Arg("signature", "Detached signature file.").String()
+	verifySigner = verifyCmd.Flag("signer", "Only accept signatures from this identity ID.").String()
+)
+
+func signFileCmd() error {
+	var identity *minilock.IdentityKeys
+	content, err := ioutil.ReadFile(*signFile)
+	if err != nil {
+		return err
+	}
+	if *keyFile != "" {
+		_, identity, err = loadKeyFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		if identity == nil {
+			return fmt.Errorf("Key file has no identity key to sign with")
+		}
+	} else {
+		email := userEmail(*signEmail)
+		if email == "" {
+			return fmt.Errorf("user-email is required unless --key-file is given or your profile has one")
+		}
+		pp, err := getPass()
+		if err != nil {
+			return err
+		}
+		identity, err = minilock.IdentityFromEmailAndPassphrase(email, pp)
+		if err != nil {
+			return err
+		}
+	}
+	defer identity.Wipe()
+	var (
+		out       []byte
+		extension string
+	)
+	if *signClear {
+		out, err = minilock.ClearSign(content, identity)
+		extension = ".asc"
+	} else {
+		var sig *minilock.Signature
+		sig, err = minilock.SignDetached(content, identity)
+		if sig != nil {
+			out = sig.Encode()
+		}
+		extension = ".sig"
+	}
+	if err != nil {
+		return err
+	}
+	if *outputFilename == "NOTGIVEN" {
+		*outputFilename = *signFile + extension
+	}
+	identityID, err := identity.EncodeID()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Signed by identity '"+identityID+"', saving to", *outputFilename)
+	return ioutil.WriteFile(*outputFilename, out, 0644)
+}
+
+func verifySigCmd() error {
+	var (
+		sig     *minilock.Signature
+		content []byte
+	)
+	signed, err := ioutil.ReadFile(*verifyFile)
+	if err != nil {
+		return err
+	}
+	if *verifySig == "" && bytes.HasPrefix(signed, []byte("-----BEGIN FMINILOCK SIGNED MESSAGE-----")) {
+		content, sig, err = minilock.VerifyClearSigned(signed)
+		if err != nil {
+			return err
+		}
+	} else {
+		if *verifySig == "" {
+			*verifySig = *verifyFile + ".sig"
+		}
+		armored, err := ioutil.ReadFile(*verifySig)
+		if err != nil {
+			return err
+		}
+		sig, err = minilock.DecodeSignature(armored)
+		if err != nil {
+			return err
+		}
+		if err = sig.Verify(signed); err != nil {
+			return err
+		}
+	}
+	if *verifySigner != "" && sig.SignerID != *verifySigner {
+		return fmt.Errorf("Good signature, but from identity '%s' rather than '%s'", sig.SignerID, *verifySigner)
+	}
+	fmt.Println("Good signature from identity '" + sig.SignerID + "'")
+	if content != nil && *outputFilename != "NOTGIVEN" {
+		return ioutil.WriteFile(*outputFilename, content, 0644)
+	}
+	return nil
+}
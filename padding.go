@@ -0,0 +1,54 @@
+package minilock
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// PaddingPadme pads the payload frame to the Padmé bucket above its length, which
+// reveals only about log(log(n)) bits of the length, at a cost of at most 12%.
+const PaddingPadme = "padme"
+
+// PaddingBlock returns the padding policy that pads the payload frame to a
+// multiple of size bytes, between 1 and taber.MaxChunkSize. Contents of all
+// lengths up to about size look alike, which suits short messages.
+func PaddingBlock(size int) string {
+	return "block:" + strconv.Itoa(size)
+}
+
+// Returns the length a frame of length n is padded to under policy.
+func paddedLength(policy string, n int) (int, error) {
+	switch {
+	case policy == "":
+		return n, nil
+	case policy == PaddingPadme:
+		return padmeLength(n), nil
+	case strings.HasPrefix(policy, "block:"):
+		size, err := strconv.Atoi(strings.TrimPrefix(policy, "block:"))
+		if err != nil || size < 1 || size > taber.MaxChunkSize {
+			return 0, ErrUnknownPadding
+		}
+		if n == 0 {
+			return size, nil
+		}
+		return (n + size - 1) / size * size, nil
+	default:
+		return 0, ErrUnknownPadding
+	}
+}
+
+// Rounds n up so that only the top log2(log2(n)) bits of its length may be set,
+// as in "Reducing Metadata Leakage from Encrypted Files and Communication with
+// PURBs" (Nikitin et al., 2019).
+func padmeLength(n int) int {
+	if n < 2 {
+		return n
+	}
+	e := bits.Len(uint(n)) - 1
+	s := bits.Len(uint(e))
+	mask := 1<<uint(e-s) - 1
+	return (n + mask) &^ mask
+}
@@ -0,0 +1,61 @@
+package minilock
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_PaddedLength(t *testing.T) {
+	for _, tc := range []struct {
+		policy    string
+		n, padded int
+	}{
+		{"", 1000, 1000},
+		{PaddingPadme, 0, 0},
+		{PaddingPadme, 9, 10},
+		{PaddingPadme, 1000, 1024},
+		{PaddingPadme, 100000, 100352},
+		{PaddingBlock(4096), 0, 4096},
+		{PaddingBlock(4096), 4096, 4096},
+		{PaddingBlock(4096), 4097, 8192},
+	} {
+		padded, err := paddedLength(tc.policy, tc.n)
+		if err != nil || padded != tc.padded {
+			t.Error("Policy ", tc.policy, " padded ", tc.n, " to ", padded, ", expected ", tc.padded, ": ", err)
+		}
+	}
+	for _, policy := range []string{"block:0", "block:-1", "block:x", "block:", "padme2"} {
+		if _, err := NewEncrypter(WithIdentity(testKey1), WithPadding(policy)); err != ErrUnknownPadding {
+			t.Error("Expected ErrUnknownPadding for ", policy, ", got: ", err)
+		}
+	}
+}
+
+func Test_PaddingRoundTrip(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	for _, policy := range []string{PaddingPadme, PaddingBlock(4096)} {
+		lengths := make(map[int]bool)
+		for _, size := range []int{3000, 3001, 3020, 3041} {
+			plaintext := bytes.Repeat([]byte{'x'}, size)
+			opts := []Option{WithIdentity(testKey1), WithRecipients(recipient), WithFilename("file.txt"), WithoutSelf(), WithPadding(policy)}
+			// Padding covers compressed contents too.
+			if size == 3000 {
+				opts = append(opts, WithCompression(CompressionGzip))
+			}
+			encrypted := mustEncrypt(t, plaintext, opts...)
+			if size != 3000 {
+				lengths[len(encrypted)] = true
+			}
+			msg, err := DecryptMessage(encrypted, recipient)
+			if err != nil {
+				t.Fatal("Couldn't decrypt with padding ", policy, ": ", err)
+			}
+			if !bytes.Equal(msg.Contents, plaintext) || msg.Padding != policy || msg.Header.Version != HeaderVersionFramed {
+				t.Error("Decrypted message didn't match for padding ", policy)
+			}
+		}
+		if len(lengths) != 1 {
+			t.Error("Padding ", policy, " didn't hide small differences in file length: ", lengths)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package minilock
+
+import (
+	"strconv"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+// A passphrase slot lets a file be decrypted with a passphrase instead of a
+// miniLock ID. The passphrase is hardened with scrypt and a random salt into a
+// box key, which is then an ordinary recipient with an ordinary decryptInfo
+// entry; the slot itself, in the clear in the header, holds only what's needed
+// to derive the key again.
+type passphraseSlot struct {
+	KDF  taber.KDFParams `json:"kdf"`
+	Salt []byte          `json:"salt"`
+}
+
+const passphraseSaltLength = 32
+
+func (slot passphraseSlot) validate() error {
+	if len(slot.Salt) != passphraseSaltLength {
+		return ErrBadPassphraseSlot
+	}
+	if err := slot.KDF.Validate(); err != nil {
+		return ErrBadPassphraseSlot
+	}
+	return nil
+}
+
+// Derives the slot's box key from passphrase.
+func (slot passphraseSlot) key(passphrase string) (*taber.Keys, error) {
+	seed, err := taber.HardenWithParams(slot.Salt, passphrase, slot.KDF)
+	if err != nil {
+		return nil, err
+	}
+	defer taber.WipeBytes(seed)
+	return taber.FromPrivate(seed)
+}
+
+// Makes a new slot for passphrase, returning it and the public key to encrypt
+// to.
+func newPassphraseSlot(passphrase string, params taber.KDFParams) (passphraseSlot, *taber.Keys, error) {
+	slot := passphraseSlot{KDF: params}
+	if err := params.Validate(); err != nil {
+		return slot, nil, err
+	}
+	salt, err := randBytes(passphraseSaltLength)
+	if err != nil {
+		return slot, nil, err
+	}
+	slot.Salt = salt
+	key, err := slot.key(passphrase)
+	if err != nil {
+		return slot, nil, err
+	}
+	defer key.Wipe()
+	return slot, key.PublicOnly(), nil
+}
+
+// The length of the passphrase slots in a JSON header, with the preceding
+// comma and key, or 0 if there are none, counted without encoding them as
+// jsonHeaderLength does.
+func jsonPassphraseSlotsLength(slots []passphraseSlot) int {
+	if len(slots) == 0 {
+		return 0
+	}
+	// The brackets, and a comma between each slot.
+	length := len(`,"passphraseSlots":[]`) + len(slots) - 1
+	for _, slot := range slots {
+		length += len(`{"kdf":{"logN":,"r":,"p":},"salt":}`)
+		length += len(strconv.Itoa(int(slot.KDF.LogN))) + len(strconv.Itoa(slot.KDF.R)) + len(strconv.Itoa(slot.KDF.P))
+		length += jsonBytesLength(slot.Salt)
+	}
+	return length
+}
+
+// Derives a key from passphrase for each of the header's passphrase slots.
+// The caller must wipe them.
+func (hdr *miniLockv1Header) passphraseKeys(passphrase string) ([]*taber.Keys, error) {
+	if len(hdr.PassphraseSlots) == 0 {
+		return nil, ErrNoPassphraseSlot
+	}
+	keys := make([]*taber.Keys, 0, len(hdr.PassphraseSlots))
+	for _, slot := range hdr.PassphraseSlots {
+		key, err := slot.key(passphrase)
+		if err != nil {
+			for _, k := range keys {
+				k.Wipe()
+			}
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// DecryptWithPassphrase parses a miniLock file and decrypts it with a
+// passphrase it was encrypted to with WithPassphraseRecipient. Files without
+// passphrase slots fail with ErrNoPassphraseSlot, and a wrong passphrase with
+// ErrCannotDecrypt. Each slot costs one scrypt derivation.
+func DecryptWithPassphrase(fileContents []byte, passphrase string, opts ...DecryptOption) (*Message, error) {
+	header, ciphertext, err := ParseFileContentsWithLimits(fileContents, newDecryptConfig(opts).limits)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := header.passphraseKeys(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, key := range keys {
+			key.Wipe()
+		}
+	}()
+	return header.DecryptMessageWithKeys(ciphertext, keys, opts...)
+}
@@ -0,0 +1,102 @@
+package minilock
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cathalgarvey/go-minilock/taber"
+)
+
+func Test_PassphraseRecipient(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	passphrase := "correct horse battery staple"
+	recipient, _ := EphemeralKey()
+
+	for name, opts := range map[string][]Option{
+		"on its own": {WithAnonymous(), WithPassphraseRecipientParams(passphrase, testKDFParams)},
+		"alongside a recipient": {WithIdentity(testKey1), WithRecipients(recipient), WithoutSelf(),
+			WithPassphraseRecipientParams(passphrase, testKDFParams)},
+	} {
+		encrypted := mustEncrypt(t, plaintext, append(opts, WithFilename("file.txt"))...)
+		hdr, ciphertext, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headerLength := len(encrypted) - len(magicBytes) - 4 - len(ciphertext)
+		if hdr.encodedLength() != headerLength {
+			t.Error("encodedLength was ", hdr.encodedLength(), " for a header of ", headerLength, " bytes with a passphrase ", name)
+		}
+		msg, err := DecryptWithPassphrase(encrypted, passphrase)
+		if err != nil {
+			t.Fatal("Couldn't decrypt with a passphrase ", name, ": ", err)
+		}
+		if !bytes.Equal(msg.Contents, plaintext) {
+			t.Error("Decrypted message didn't match with a passphrase ", name)
+		}
+		if _, err = DecryptWithPassphrase(encrypted, "wrong horse battery staple"); err != ErrCannotDecrypt {
+			t.Error("Expected ErrCannotDecrypt for the wrong passphrase ", name, ", got: ", err)
+		}
+	}
+
+	// Alongside a recipient, the recipient can still decrypt.
+	encrypted := mustEncrypt(t, plaintext, WithIdentity(testKey1), WithRecipients(recipient), WithPassphraseRecipientParams(passphrase, testKDFParams))
+	if msg, err := DecryptMessage(encrypted, recipient); err != nil || !bytes.Equal(msg.Contents, plaintext) {
+		t.Error("Recipient couldn't decrypt a file with a passphrase slot: ", err)
+	}
+
+	encrypted, err := EncryptFileContents("file.txt", plaintext, recipient, recipient, testKey1, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptWithPassphrase(encrypted, passphrase); err != ErrNoPassphraseSlot {
+		t.Error("Expected ErrNoPassphraseSlot, got: ", err)
+	}
+
+	e, err := NewEncrypter(WithAnonymous(), WithPassphraseRecipientParams(passphrase, testKDFParams), WithHeaderVersion(HeaderVersionBinary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = e.Encrypt(plaintext); err != ErrPassphraseSlotVersion {
+		t.Error("Expected ErrPassphraseSlotVersion for a binary header, got: ", err)
+	}
+	if _, err = NewEncrypter(WithAnonymous(), WithPassphraseRecipientParams(passphrase, taber.KDFParams{LogN: 40, R: 8, P: 1})); err != taber.ErrBadKDFParams {
+		t.Error("Expected ErrBadKDFParams, got: ", err)
+	}
+}
+
+func Test_HostilePassphraseSlots(t *testing.T) {
+	encrypted := mustEncrypt(t, []byte("Some file contents."), WithAnonymous(), WithPassphraseRecipientParams("passphrase", testKDFParams))
+	hdr, ciphertext, err := ParseFileContents(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot := hdr.PassphraseSlots[0]
+	for name, tc := range map[string]struct {
+		version int
+		slots   []passphraseSlot
+		err     error
+	}{
+		"short salt":      {HeaderVersionFMiniLock, []passphraseSlot{{KDF: slot.KDF, Salt: slot.Salt[:16]}}, ErrBadPassphraseSlot},
+		"costly KDF":      {HeaderVersionFMiniLock, []passphraseSlot{{KDF: taber.KDFParams{LogN: 22, R: 32, P: 16}, Salt: slot.Salt}}, ErrBadPassphraseSlot},
+		"too many slots":  {HeaderVersionFMiniLock, []passphraseSlot{slot, slot, slot, slot, slot}, ErrTooManyPassphraseSlots},
+		"miniLock header": {HeaderVersionMiniLock, []passphraseSlot{slot}, ErrPassphraseSlotVersion},
+	} {
+		hdr.Version, hdr.PassphraseSlots = tc.version, tc.slots
+		_, _, err = ParseFileContents(restuff(t, hdr, ciphertext))
+		if !errors.Is(err, tc.err) || !errors.Is(err, ErrMalformed) {
+			t.Error("Expected ", tc.err, " for ", name, ", got: ", err)
+		}
+	}
+	// A lower limit refuses slots that taber would accept.
+	hdr.Version, hdr.PassphraseSlots = HeaderVersionFMiniLock, []passphraseSlot{{KDF: taber.KDFParams{LogN: 17, R: 8, P: 1}, Salt: slot.Salt}}
+	limits := DefaultLimits
+	limits.MaxKDFMemory = 64 << 20
+	_, _, err = ParseFileContentsWithLimits(restuff(t, hdr, ciphertext), limits)
+	if !errors.Is(err, ErrPassphraseSlotTooCostly) || !errors.Is(err, ErrMalformed) {
+		t.Error("Expected ErrPassphraseSlotTooCostly under a 64 MiB limit, got: ", err)
+	}
+	if _, _, err = ParseFileContents(restuff(t, hdr, ciphertext)); err != nil {
+		t.Error("Expected a 128 MiB slot to be accepted by default, got: ", err)
+	}
+}
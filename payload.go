@@ -0,0 +1,131 @@
+package minilock
+
+import (
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Files that carry metadata, or are compressed or padded, are written with a
+// framed payload, under a header version that says so. Within the encrypted stream,
+// after the filename block, the payload is laid out as follows, with integers
+// little-endian:
+//
+//	length      uint32, the length of the frame header
+//	header      the frame header, JSON
+//	body        the file contents, compressed in blocks if the frame header
+//	            says so
+//	padding     zero bytes
+//	padLength   uint64, the number of padding bytes
+//
+// All of it is encrypted and authenticated along with the contents, so nothing
+// in it shows in the miniLock header, and the padding hides the lengths of the
+// rest: the padding policy applies to the whole frame.
+const (
+	frameLengthSize = 4
+	framePadSize    = 8
+)
+
+// The frame header describes how to read the body and what the file is.
+type frameHeader struct {
+	// Compression is the algorithm, such as CompressionGzip, the body was
+	// compressed with, or empty if it wasn't.
+	Compression string `json:"compression,omitempty"`
+	// Padding is the policy, such as PaddingPadme, the frame was padded
+	// under, or empty if it wasn't.
+	Padding  string    `json:"padding,omitempty"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// The frame header fields this package knows. Other fields may change how the
+// body must be read, so frames holding them are refused; fields within
+// Metadata only describe the file, and unknown ones are ignored.
+var frameHeaderFields = map[string]bool{
+	"compression": true,
+	"padding":     true,
+	"metadata":    true,
+}
+
+// Whether files encrypted with settings need a framed payload.
+func (settings encryptSettings) needsFrame() bool {
+	return settings.metadata != nil || settings.compression != "" || settings.padding != ""
+}
+
+// The header version to write: the one asked for, or HeaderVersionFMiniLock,
+// moved to its framed counterpart if the payload needs a frame.
+func (settings encryptSettings) resolveVersion() (int, error) {
+	version := settings.headerVersion
+	if version == 0 {
+		version = HeaderVersionFMiniLock
+	}
+	format, ok := headerFormats[version]
+	if !ok {
+		return 0, &VersionError{version}
+	}
+	if settings.needsFrame() && !format.framed {
+		if format.framedVersion == 0 {
+			return 0, ErrFramedPayloadVersion
+		}
+		version = format.framedVersion
+	}
+	return version, nil
+}
+
+// Frames body under header, padded under header.Padding.
+func frame(header *frameHeader, body []byte) ([]byte, error) {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	unpadded := frameLengthSize + len(encoded) + len(body) + framePadSize
+	length, err := paddedLength(header.Padding, unpadded)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, frameLengthSize, length)
+	binary.LittleEndian.PutUint32(payload, uint32(len(encoded)))
+	payload = append(payload, encoded...)
+	payload = append(payload, body...)
+	// The padding and its length are zero bytes so far.
+	payload = payload[:length]
+	binary.LittleEndian.PutUint64(payload[length-framePadSize:], uint64(length-unpadded))
+	return payload, nil
+}
+
+// Splits a framed payload into its header and body.
+func unframe(payload []byte) (*frameHeader, []byte, error) {
+	if len(payload) < frameLengthSize+framePadSize {
+		return nil, nil, ErrBadFrame
+	}
+	padLength := binary.LittleEndian.Uint64(payload[len(payload)-framePadSize:])
+	rest := payload[frameLengthSize : len(payload)-framePadSize]
+	if padLength > uint64(len(rest)) {
+		return nil, nil, ErrBadPadding
+	}
+	rest = rest[:uint64(len(rest))-padLength]
+	headerLength := binary.LittleEndian.Uint32(payload)
+	if uint64(headerLength) > uint64(len(rest)) {
+		return nil, nil, ErrBadFrame
+	}
+	header, err := decodeFrameHeader(rest[:headerLength])
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, rest[headerLength:], nil
+}
+
+func decodeFrameHeader(encoded []byte) (*frameHeader, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, ErrBadFrame
+	}
+	for name := range fields {
+		if !frameHeaderFields[name] {
+			return nil, ErrUnknownFrameField
+		}
+	}
+	header := new(frameHeader)
+	if err := json.Unmarshal(encoded, header); err != nil {
+		return nil, ErrBadFrame
+	}
+	return header, nil
+}
@@ -0,0 +1,157 @@
+package minilock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func Test_FramedPayloadVersions(t *testing.T) {
+	plaintext := []byte("Some file contents.")
+	recipient, _ := EphemeralKey()
+	md := &Metadata{ContentType: "text/plain"}
+	for _, tc := range []struct {
+		asked, written int
+	}{
+		{0, HeaderVersionFramed},
+		{HeaderVersionFMiniLock, HeaderVersionFramed},
+		{HeaderVersionBinary, HeaderVersionBinaryFramed},
+		{HeaderVersionFramed, HeaderVersionFramed},
+	} {
+		opts := []Option{WithIdentity(testKey1), WithRecipients(recipient), WithMetadata(md)}
+		if tc.asked != 0 {
+			opts = append(opts, WithHeaderVersion(tc.asked))
+		}
+		encrypted := mustEncrypt(t, plaintext, opts...)
+		msg, err := DecryptMessage(encrypted, recipient)
+		if err != nil {
+			t.Fatal("Couldn't decrypt a framed file asking for version ", tc.asked, ": ", err)
+		}
+		if msg.Header.Version != tc.written || !bytes.Equal(msg.Contents, plaintext) || msg.Metadata == nil {
+			t.Error("Expected version ", tc.written, " for version ", tc.asked, ", got: ", msg.Header.Version)
+		}
+	}
+
+	// A framed version with nothing to frame still frames.
+	encrypted := mustEncrypt(t, plaintext, WithIdentity(testKey1), WithRecipients(recipient), WithHeaderVersion(HeaderVersionFramed))
+	if msg, err := DecryptMessage(encrypted, recipient); err != nil || !bytes.Equal(msg.Contents, plaintext) || msg.Metadata != nil {
+		t.Error("Couldn't decrypt an empty frame: ", err)
+	}
+
+	e, err := NewEncrypter(WithIdentity(testKey1), WithRecipients(recipient), WithMetadata(md), WithHeaderVersion(HeaderVersionMiniLock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = e.Encrypt(plaintext); err != ErrFramedPayloadVersion {
+		t.Error("Expected ErrFramedPayloadVersion for a miniLock header, got: ", err)
+	}
+}
+
+// Relabelling the header version, which isn't authenticated, is caught by the
+// file info, which is.
+func Test_FramedPayloadRelabelled(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	for _, tc := range []struct {
+		opts     []Option
+		relabel  int
+		contents string
+	}{
+		{[]Option{WithMetadata(&Metadata{ContentType: "text/plain"})}, HeaderVersionFMiniLock, "framed as unframed"},
+		{nil, HeaderVersionFramed, "unframed as framed"},
+	} {
+		encrypted := mustEncrypt(t, []byte("Some file contents."), append(tc.opts, WithIdentity(testKey1), WithRecipients(recipient))...)
+		hdr, ciphertext, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr.Version = tc.relabel
+		_, err = DecryptMessage(restuff(t, hdr, ciphertext), recipient)
+		if !errors.Is(err, ErrFramedPayloadVersion) || !errors.Is(err, ErrMalformed) {
+			t.Error("Expected ErrFramedPayloadVersion for a file relabelled ", tc.contents, ", got: ", err)
+		}
+	}
+}
+
+// However large the metadata, it adds nothing to the header.
+func Test_MetadataOutsideHeader(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	headerLength := -1
+	for _, md := range []*Metadata{
+		{},
+		{ContentType: "text/plain", Extra: map[string]string{"notes": string(make([]byte, 1000))}},
+	} {
+		encrypted := mustEncrypt(t, []byte("Some file contents."), WithIdentity(testKey1), WithRecipients(recipient), WithoutSelf(), WithMetadata(md))
+		_, ciphertext, err := ParseFileContents(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		length := len(encrypted) - len(ciphertext)
+		if headerLength >= 0 && length != headerLength {
+			t.Error("Header length depends on metadata: ", headerLength, " and ", length)
+		}
+		headerLength = length
+	}
+}
+
+func Test_HostileFrames(t *testing.T) {
+	body := []byte("Some file contents.")
+	framed, err := frame(&frameHeader{Metadata: &Metadata{ContentType: "text/plain"}}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, got, err := unframe(framed)
+	if err != nil || !bytes.Equal(got, body) || header.Metadata == nil || header.Metadata.ContentType != "text/plain" {
+		t.Fatal("Frame didn't round-trip: ", err)
+	}
+	padded, err := frame(&frameHeader{Padding: PaddingBlock(4096)}, body)
+	if err != nil || len(padded) != 4096 {
+		t.Fatal("Expected a 4096 byte frame, got ", len(padded), ": ", err)
+	}
+	if header, got, err = unframe(padded); err != nil || !bytes.Equal(got, body) || header.Padding != PaddingBlock(4096) {
+		t.Fatal("Padded frame didn't round-trip: ", err)
+	}
+	// Metadata fields from later versions are ignored.
+	if _, _, err = unframe(rawFrame(`{"metadata":{"colour":"blue"}}`, body, 0)); err != nil {
+		t.Error("Expected unknown metadata fields to be ignored, got: ", err)
+	}
+
+	withHeaderLength := func(length uint32) []byte {
+		f := append([]byte(nil), framed...)
+		binary.LittleEndian.PutUint32(f, length)
+		return f
+	}
+	for name, tc := range map[string]struct {
+		payload []byte
+		err     error
+	}{
+		"empty":                 {nil, ErrBadFrame},
+		"truncated":             {framed[:framePadSize], ErrBadFrame},
+		"header past end":       {withHeaderLength(uint32(len(framed))), ErrBadFrame},
+		"huge header":           {withHeaderLength(^uint32(0)), ErrBadFrame},
+		"padding past end":      {rawFrame(`{}`, body, uint64(len(body))+3), ErrBadPadding},
+		"huge padding":          {rawFrame(`{}`, body, ^uint64(0)), ErrBadPadding},
+		"not JSON":              {rawFrame(`{"metadata"`, body, 0), ErrBadFrame},
+		"unknown field":         {rawFrame(`{"encoding":"base64"}`, body, 0), ErrUnknownFrameField},
+		"bad metadata":          {rawFrame(`{"metadata":{"mode":"rwx"}}`, body, 0), ErrBadFrame},
+		"padding eats header":   {rawFrame(`{}`, nil, 1), ErrBadFrame},
+		"header not an object":  {rawFrame(`[]`, body, 0), ErrBadFrame},
+		"metadata not a object": {rawFrame(`{"metadata":7}`, body, 0), ErrBadFrame},
+	} {
+		if _, _, err = unframe(tc.payload); !errors.Is(err, tc.err) || !errors.Is(err, ErrMalformed) {
+			t.Error("Expected ", tc.err, " for ", name, ", got: ", err)
+		}
+	}
+}
+
+// Builds a frame from its parts, claiming padLength bytes of padding whether
+// or not they are there.
+func rawFrame(header string, body []byte, padLength uint64) []byte {
+	f := make([]byte, frameLengthSize)
+	binary.LittleEndian.PutUint32(f, uint32(len(header)))
+	f = append(f, header...)
+	f = append(f, body...)
+	pad := make([]byte, framePadSize)
+	binary.LittleEndian.PutUint64(pad, padLength)
+	return append(f, pad...)
+}
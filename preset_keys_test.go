@@ -1,5 +1,9 @@
 package minilock
 
+import (
+	"testing"
+)
+
 var (
 	testKey1ID         = "2Ddpk7j3cnyHRUNbukQTEagXFBHSGZV4suemTjEKyZs6BF"
 	testKey2ID         = "bgJMMiCJiJL1jq48rkWc8cUfkuQWjRYKR44sHgK2kiUj1"
@@ -12,3 +16,21 @@ func init() {
 	testKey1, _ = IdentityFromEmailAndPassphrase("cathalgarvey@some.where", "this is a password that totally works for minilock purposes")
 	testKey2, _ = IdentityFromEmailAndPassphrase("joeblocks@else.where", "whatever I write won't be good enough for the NSA")
 }
+
+// Encrypts plaintext with an Encrypter made from opts, failing the test on any
+// error. A new reply-to key is wiped; one given with WithReplyTo is left alone.
+func mustEncrypt(t *testing.T, plaintext []byte, opts ...Option) []byte {
+	t.Helper()
+	e, err := NewEncrypter(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, replyTo, err := e.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal("Couldn't encrypt: ", err)
+	}
+	if replyTo != nil && e.replyTo == nil {
+		replyTo.Wipe()
+	}
+	return encrypted
+}
@@ -0,0 +1,142 @@
+package minilock
+
+import (
+	"bytes"
+	"encoding/pem"
+
+	"github.com/agl/ed25519"
+	"github.com/dchest/blake2s"
+)
+
+const (
+	signatureBlockType     = "FMINILOCK SIGNATURE"
+	signedMessageBeginLine = "-----BEGIN FMINILOCK SIGNED MESSAGE-----\n"
+	signatureSignerHeader  = "Signer"
+	signatureVersionHeader = "Version"
+	signatureVersion       = "1"
+)
+
+// Prefixed to the content hash before signing, so that signatures over
+// published files can never be confused with DecryptInfoEntry verifications.
+var signatureContext = []byte("fminilock signed content v1\x00")
+
+// Signature is a signature by an identity over some content that is published
+// unencrypted, such as a release artifact. It proves which identity the content
+// came from without hiding it.
+type Signature struct {
+	SignerID  string
+	Signature []byte
+}
+
+func signedDigest(content []byte) []byte {
+	hash := blake2s.Sum256(content)
+	return append(append([]byte{}, signatureContext...), hash[:]...)
+}
+
+// SignDetached signs content with identity, returning a signature to be
+// published alongside it.
+func SignDetached(content []byte, identity *IdentityKeys) (*Signature, error) {
+	signerID, err := identity.EncodeID()
+	if err != nil {
+		return nil, err
+	}
+	sig := identity.Sign(signedDigest(content))
+	if sig == nil {
+		return nil, ErrCannotSign
+	}
+	return &Signature{SignerID: signerID, Signature: sig}, nil
+}
+
+// Verify checks that the signature is valid for content, returning a
+// *SignatureError if not. It says nothing about whether the signer is trusted;
+// compare SignerID against the identity you expect.
+func (s *Signature) Verify(content []byte) error {
+	signer, err := IdentityFromID(s.SignerID)
+	if err != nil {
+		return err
+	}
+	if !signer.Verify(signedDigest(content), s.Signature) {
+		return &SignatureError{SignerID: s.SignerID}
+	}
+	return nil
+}
+
+// Encode returns the signature as an ASCII-armored block.
+func (s *Signature) Encode() []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type: signatureBlockType,
+		Headers: map[string]string{
+			signatureVersionHeader: signatureVersion,
+			signatureSignerHeader:  s.SignerID,
+		},
+		Bytes: s.Signature,
+	})
+}
+
+// DecodeSignature parses an ASCII-armored signature block made by Encode.
+func DecodeSignature(armored []byte) (*Signature, error) {
+	block, _ := pem.Decode(armored)
+	if block == nil || block.Type != signatureBlockType {
+		return nil, ErrMalformedSignature
+	}
+	if block.Headers[signatureVersionHeader] != signatureVersion {
+		return nil, ErrMalformedSignature
+	}
+	if len(block.Bytes) != ed25519.SignatureSize || block.Headers[signatureSignerHeader] == "" {
+		return nil, ErrMalformedSignature
+	}
+	return &Signature{SignerID: block.Headers[signatureSignerHeader], Signature: block.Bytes}, nil
+}
+
+// ClearSign wraps text content and its signature into a single readable
+// document. Lines of content beginning with a dash are escaped with "- ", so
+// that they can't be mistaken for the armor; VerifyClearSigned undoes this.
+func ClearSign(content []byte, identity *IdentityKeys) ([]byte, error) {
+	sig, err := SignDetached(content, identity)
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.NewBufferString(signedMessageBeginLine)
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("-")) {
+			out.WriteString("- ")
+		}
+		out.Write(line)
+	}
+	// Content is always followed by exactly one newline before the signature.
+	out.WriteByte('\n')
+	out.Write(sig.Encode())
+	return out.Bytes(), nil
+}
+
+// VerifyClearSigned checks a document made by ClearSign, returning the original
+// content and the verified signature.
+func VerifyClearSigned(signed []byte) (content []byte, sig *Signature, err error) {
+	if !bytes.HasPrefix(signed, []byte(signedMessageBeginLine)) {
+		return nil, nil, ErrMalformedSignature
+	}
+	body := signed[len(signedMessageBeginLine):]
+	sigStart := bytes.LastIndex(body, []byte("\n-----BEGIN "+signatureBlockType+"-----"))
+	if sigStart < 0 {
+		return nil, nil, ErrMalformedSignature
+	}
+	sig, err = DecodeSignature(body[sigStart+1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	body = body[:sigStart]
+	content = make([]byte, 0, len(body))
+	for _, line := range bytes.SplitAfter(body, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("-")) {
+			if !bytes.HasPrefix(line, []byte("- ")) {
+				return nil, nil, ErrMalformedSignature
+			}
+			line = line[2:]
+		}
+		content = append(content, line...)
+	}
+	if err = sig.Verify(content); err != nil {
+		return nil, nil, err
+	}
+	return content, sig, nil
+}
@@ -0,0 +1,71 @@
+package minilock
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_DetachedSignature(t *testing.T) {
+	content := []byte("release-1.0.tar.gz contents, or near enough")
+	sig, err := SignDetached(content, testKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.SignerID != testKey1ID {
+		t.Error("Signature names the wrong signer:", sig.SignerID)
+	}
+	decoded, err := DecodeSignature(sig.Encode())
+	if err != nil {
+		t.Fatal("Failed to decode armored signature: ", err)
+	}
+	if err = decoded.Verify(content); err != nil {
+		t.Error("Valid signature failed to verify: ", err)
+	}
+	if err = decoded.Verify(append(content, '!')); !errors.Is(err, ErrBadSignature) {
+		t.Error("Expected ErrBadSignature for altered content, got:", err)
+	}
+	decoded.SignerID = testKey2ID
+	if err = decoded.Verify(content); !errors.Is(err, ErrBadSignature) {
+		t.Error("Expected ErrBadSignature for the wrong signer, got:", err)
+	}
+	public, err := IdentityFromID(testKey1ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SignDetached(content, public); err != ErrCannotSign {
+		t.Error("Expected ErrCannotSign signing with a public-only identity, got:", err)
+	}
+}
+
+func Test_ClearSign(t *testing.T) {
+	for _, content := range [][]byte{
+		[]byte("A short announcement.\n"),
+		[]byte("No trailing newline"),
+		[]byte("--- a/file\n+++ b/file\n-----BEGIN FMINILOCK SIGNATURE-----\n- dashes\n"),
+		[]byte{},
+	} {
+		signed, err := ClearSign(content, testKey2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verified, sig, err := VerifyClearSigned(signed)
+		if err != nil {
+			t.Fatal("Failed to verify clearsigned message: ", err, "\n", string(signed))
+		}
+		if sig.SignerID != testKey2ID {
+			t.Error("Clearsigned message names the wrong signer:", sig.SignerID)
+		}
+		if !bytes.Equal(verified, content) {
+			t.Errorf("Clearsigned content changed in transit: %q became %q", content, verified)
+		}
+	}
+	signed, err := ClearSign([]byte("Pay Alice 10 coins.\n"), testKey2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(signed, []byte("10"), []byte("99"), 1)
+	if _, _, err = VerifyClearSigned(tampered); !errors.Is(err, ErrBadSignature) {
+		t.Error("Expected ErrBadSignature for tampered clearsigned message, got:", err)
+	}
+}
@@ -23,14 +23,15 @@ type block struct {
 	err error
 }
 
-// BeginsLocation predicts where a block of ciphertext should/would begin in the ciphertext.
-func (blk *block) BeginsLocation() int {
-	return ConstFilenameBlockLength + (blk.Index-1)*ConstBlockLength
+// BeginsLocation predicts where a block of ciphertext should/would begin in the
+// ciphertext, after a name block of nameBlockLength, given the chunk size.
+func (blk *block) BeginsLocation(nameBlockLength, chunkSize int) int {
+	return nameBlockLength + (blk.Index-1)*(chunkSize+ConstBlockOverhead)
 }
 
 // ChunkLength returns the length of the enclosed plaintext chunk by subtracting
 // the length of the encryption/encoding bytes.
 func (blk *block) ChunkLength() int {
 	// Don't use headers, they can't be trusted!
-	return len(blk.Block) - (16 + 4)
+	return len(blk.Block) - ConstBlockOverhead
 }
@@ -8,10 +8,10 @@ type enumeratedChunk struct {
 	err   error
 }
 
-func (enChk *enumeratedChunk) beginsLocation() int {
-	return enChk.index * ConstChunkSize
+func (enChk *enumeratedChunk) beginsLocation(chunkSize int) int {
+	return enChk.index * chunkSize
 }
 
-func (enChk *enumeratedChunk) endsLocation() int {
-	return enChk.beginsLocation() + len(enChk.chunk)
+func (enChk *enumeratedChunk) endsLocation(chunkSize int) int {
+	return enChk.beginsLocation(chunkSize) + len(enChk.chunk)
 }
@@ -2,6 +2,8 @@ package taber
 
 import (
 	"bytes"
+	"crypto/rand"
+	"io"
 	"sync"
 	"time"
 
@@ -11,17 +13,31 @@ import (
 // Uses length prefixes to parse miniLock ciphertext and return a slice of
 // block objects for decryption.
 func walkCiphertext(ciphertext []byte) ([]block, error) {
+	return walkCiphertextChunks(ciphertext, ConstChunkSize)
+}
+
+// As walkCiphertext, for ciphertext in chunks of chunkSize.
+func walkCiphertextChunks(ciphertext []byte, chunkSize int) ([]block, error) {
+	if len(ciphertext) == 0 {
+		return nil, ErrTruncatedCiphertext
+	}
 	// Enough room for all full blocks, plus the last block, plus the name block.
-	blocks := make([]block, 0, ((len(ciphertext)-256)/ConstBlockLength)+2)
+	blocks := make([]block, 0, ((len(ciphertext)-ConstFilenameBlockLength)/(chunkSize+ConstBlockOverhead))+2)
 	blockIndex := 0
 	for loc := 0; loc < len(ciphertext); {
-		//fmt.Println("walkCiphertext: loc =", loc)
+		if len(ciphertext)-loc < 4 {
+			return nil, ErrTruncatedCiphertext
+		}
 		prefixLEb := ciphertext[loc : loc+4]
 		prefixInt32, err := fromLittleEndian(prefixLEb)
 		if err != nil {
 			return nil, err
 		}
-		blockEnds := loc + prefixToBlockL(int(prefixInt32))
+		prefix := int(prefixInt32)
+		if prefix < 0 || prefix > chunkSize {
+			return nil, ErrBadLengthPrefix
+		}
+		blockEnds := loc + prefixToBlockL(prefix)
 		if blockEnds > len(ciphertext) {
 			return nil, ErrBadLengthPrefix
 		}
@@ -39,14 +55,17 @@ func walkCiphertext(ciphertext []byte) ([]block, error) {
 
 func decryptBlock(key, baseNonce []byte, block *block) ([]byte, error) {
 	var auth bool
+	if len(key) != 32 {
+		return nil, ErrBadKeyLength
+	}
 	chunkNonce, err := makeChunkNonce(baseNonce, block.Index, block.last)
 	if err != nil {
 		return nil, err
 	}
 	plaintext := make([]byte, 0, len(block.Block)-(secretbox.Overhead+4))
-	plaintext, auth = secretbox.Open(plaintext, block.Block[4:], nonceToArray(chunkNonce), keyToArray(key))
+	plaintext, auth = secretbox.Open(plaintext, block.Block[4:], nonceToArray(chunkNonce), (*[32]byte)(key))
 	if !auth {
-		return nil, ErrBadBoxAuth
+		return nil, &ChunkAuthError{Index: block.Index}
 	}
 	return plaintext, nil
 }
@@ -62,28 +81,28 @@ func decryptName(key, baseNonce []byte, nameBlock *block) (string, error) {
 	return string(fnBytes), nil
 }
 
-func reassemble(plaintext []byte, chunksChan chan *enumeratedChunk, done chan bool) ([]byte, error) {
+func reassemble(plaintext []byte, chunkSize int, chunksChan chan *enumeratedChunk, done chan bool) ([]byte, error) {
 	for {
 		select {
 		case echunk := <-chunksChan:
 			{
-				if echunk.err != nil {
-					return nil, echunk.err
-				}
-				b := echunk.beginsLocation()
-				e := echunk.endsLocation()
-				// End is calculated using length prefixes so must be regarded as bad
-				if e > len(plaintext) {
-					return nil, ErrBoxDecryptionEOP
+				if err := placeChunk(plaintext, chunkSize, echunk); err != nil {
+					return nil, err
 				}
-				if len(echunk.chunk) > len(plaintext[b:e]) {
-					return nil, ErrBoxDecryptionEOS
-				}
-				copy(plaintext[b:e], echunk.chunk)
 			}
 		case <-done:
 			{
-				return plaintext, nil
+				// Every chunk has been sent by now, but some may still be buffered.
+				for {
+					select {
+					case echunk := <-chunksChan:
+						if err := placeChunk(plaintext, chunkSize, echunk); err != nil {
+							return nil, err
+						}
+					default:
+						return plaintext, nil
+					}
+				}
 			}
 		default:
 			{
@@ -93,6 +112,23 @@ func reassemble(plaintext []byte, chunksChan chan *enumeratedChunk, done chan bo
 	}
 }
 
+func placeChunk(plaintext []byte, chunkSize int, echunk *enumeratedChunk) error {
+	if echunk.err != nil {
+		return echunk.err
+	}
+	b := echunk.beginsLocation(chunkSize)
+	e := echunk.endsLocation(chunkSize)
+	// End is calculated using length prefixes so must be regarded as bad
+	if e > len(plaintext) {
+		return ErrBoxDecryptionEOP
+	}
+	if len(echunk.chunk) > len(plaintext[b:e]) {
+		return ErrBoxDecryptionEOS
+	}
+	copy(plaintext[b:e], echunk.chunk)
+	return nil
+}
+
 func decryptBlockAsync(key, baseNonce []byte, thisBlock *block, chunksChan chan *enumeratedChunk, wg *sync.WaitGroup) {
 	// Insert decryption code here
 	var echunk *enumeratedChunk
@@ -108,15 +144,41 @@ func decryptBlockAsync(key, baseNonce []byte, thisBlock *block, chunksChan chan
 
 // Parse blocks, fan-out using decryptBlock, re-assemble to original plaintext.
 func decrypt(key, baseNonce, ciphertext []byte) (filename string, plaintext []byte, err error) {
-	blocks, err := walkCiphertext(ciphertext)
+	return decryptChunks(key, baseNonce, ciphertext, ConstChunkSize)
+}
+
+// As decrypt, for ciphertext in chunks of chunkSize.
+func decryptChunks(key, baseNonce, ciphertext []byte, chunkSize int) (filename string, plaintext []byte, err error) {
+	if !ValidChunkSize(chunkSize) {
+		return "", nil, ErrBadChunkSize
+	}
+	chunkSize = effectiveChunkSize(chunkSize)
+	blocks, err := walkCiphertextChunks(ciphertext, chunkSize)
 	if err != nil {
 		return "", nil, err
 	}
+	// The name block and at least one chunk, even if empty.
+	if len(blocks) < 2 {
+		return "", nil, ErrTruncatedCiphertext
+	}
+	// The name block holds a padded filename, or nothing for unnamed files.
+	if nameLength := blocks[0].ChunkLength(); nameLength != 256 && nameLength != 0 {
+		return "", nil, ErrBadLengthPrefix
+	}
 	filename, err = decryptName(key, baseNonce, &blocks[0])
 	if err != nil {
 		return "", nil, err
 	}
-	chunksChan := make(chan *enumeratedChunk)
+	if len(blocks) == 2 {
+		// Small files and messages aren't worth fanning out.
+		plaintext, err = decryptBlock(key, baseNonce, &blocks[1])
+		if err != nil {
+			return "", nil, err
+		}
+		return filename, plaintext, nil
+	}
+	// Buffered so that workers don't leak if reassembly stops at an error.
+	chunksChan := make(chan *enumeratedChunk, len(blocks)-1)
 	expectedLength := 0
 	wg := new(sync.WaitGroup)
 	for _, thisBlock := range blocks[1:] {
@@ -129,13 +191,13 @@ func decrypt(key, baseNonce, ciphertext []byte) (filename string, plaintext []by
 	// function will throw an error.
 	plaintext = make([]byte, expectedLength)
 	// Translates the blocking WaitGroup into non-blocking chan bool "done".
-	done := make(chan bool)
+	done := make(chan bool, 1)
 	go func(done chan bool, wg *sync.WaitGroup) {
 		wg.Wait()
 		done <- true
 	}(done, wg)
 	// Awaits chunks on chunksChan until sent on done.
-	plaintext, err = reassemble(plaintext, chunksChan, done)
+	plaintext, err = reassemble(plaintext, chunkSize, chunksChan, done)
 	if err != nil {
 		return "", nil, err
 	}
@@ -148,19 +210,42 @@ func decrypt(key, baseNonce, ciphertext []byte) (filename string, plaintext []by
 type DecryptInfo struct {
 	// Decryption key (32 bytes) and Nonce (24 bytes) required to decrypt.
 	Key, BaseNonce []byte
+	// ChunkSize is the length of the plaintext chunks; zero means
+	// ConstChunkSize. Set it before encrypting to choose another.
+	ChunkSize int
+
+	// Locked memory backing Key, for keys generated by this package.
+	secure *SecureBuffer
 }
 
 // NewDecryptInfo returns a prepared DecryptInfo with a new Symmetric Key and BaseNonce.
 func NewDecryptInfo() (*DecryptInfo, error) {
-	key, err := makeSymmetricKey()
+	return NewDecryptInfoFrom(rand.Reader)
+}
+
+// NewDecryptInfoFrom returns a prepared DecryptInfo with a Symmetric Key and
+// BaseNonce read from r, which must be a secure random source outside of tests.
+func NewDecryptInfoFrom(r io.Reader) (*DecryptInfo, error) {
+	key, err := randBytesFrom(r, 32)
 	if err != nil {
 		return nil, err
 	}
-	nonce, err := makeBaseNonce()
+	nonce, err := randBytesFrom(r, 16)
 	if err != nil {
 		return nil, err
 	}
-	return &DecryptInfo{Key: key, BaseNonce: nonce}, nil
+	secure := secureCopy(key)
+	return &DecryptInfo{Key: secure.Bytes(), BaseNonce: nonce, secure: secure}, nil
+}
+
+// Wipe zeroes the symmetric key; call it when finished with a DecryptInfo.
+// Any slices sharing the key, such as a FileInfo's FileKey, are zeroed too.
+func (di *DecryptInfo) Wipe() {
+	if di.secure != nil {
+		di.secure.Wipe()
+	} else {
+		WipeBytes(di.Key)
+	}
 }
 
 // Validate returns simply that the Key and BaseNonce look OK. It's not very clever,
@@ -175,5 +260,5 @@ func (di *DecryptInfo) Decrypt(ciphertext []byte) (filename string, plaintext []
 	if !di.Validate() {
 		return "", nil, ErrBadBoxDecryptVars
 	}
-	return decrypt(di.Key, di.BaseNonce, ciphertext)
+	return decryptChunks(di.Key, di.BaseNonce, ciphertext, di.ChunkSize)
 }
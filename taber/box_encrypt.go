@@ -25,6 +25,9 @@ func makeChunkNonce(base_nonce []byte, chunk_number int, last bool) ([]byte, err
 }
 
 func encryptChunk(key, base_nonce, chunk []byte, index int, last bool) (*block, error) {
+	if len(key) != 32 {
+		return nil, ErrBadKeyLength
+	}
 	// Handling of last-chunk is done using the chunk nonce,
 	chunk_nonce, err := makeChunkNonce(base_nonce, index, last)
 	if err != nil {
@@ -42,7 +45,7 @@ func encryptChunk(key, base_nonce, chunk []byte, index int, last bool) (*block,
 	}
 	copy(ciphertext, bl_len)
 	// Put the ciphertext in the space after the first four bytes.
-	ciphertext = secretbox.Seal(ciphertext, chunk, nonceToArray(chunk_nonce), keyToArray(key))
+	ciphertext = secretbox.Seal(ciphertext, chunk, nonceToArray(chunk_nonce), (*[32]byte)(key))
 	// Get 4-byte length prefix, verify it's the right length JIC.
 	return &block{Block: ciphertext, Index: index}, nil
 }
@@ -61,44 +64,19 @@ func prepareNameChunk(filename string) ([]byte, error) {
 
 // Chunk up a file and encrypt each chunk separately, returning each chunk through
 // block_chan for reassembly. Blocks can and will arrive out of order through block_chan.
-func encryptToChan(filename string, key, base_nonce, file_data []byte, block_chan chan *block, done chan bool) (err error) {
-	if base_nonce == nil {
-		base_nonce, err = makeBaseNonce()
-		if err != nil {
-			return err
-		}
-	}
-	filename_chunk, err := prepareNameChunk(filename)
-	if err != nil {
-		return err
-	}
-	fn_block, err := encryptChunk(key, base_nonce, filename_chunk, 0, false)
-	if err != nil {
-		return err
-	}
-	block_chan <- fn_block
-	// Get expected chunk number so special treatment of last chunk can be done
-	// correctly.
-	num_chunks := numChunks(len(file_data), ConstChunkSize)
+func encryptToChan(key, base_nonce []byte, chunks [][]byte, block_chan chan *block, done chan bool) {
+	num_chunks := len(chunks)
 	wg := new(sync.WaitGroup)
-	for i, chunk := range chunkify(file_data, ConstChunkSize) {
+	for i, chunk := range chunks {
 		block_number := i + 1
 		wg.Add(1)
 		// Fan out the job of encrypting each chunk. Each ciphertext block gets passed
 		// back through block_chan. WaitGroup wg makes sure all goroutines are finished
 		// prior to passing back "done".
 		go func(key, base_nonce, chunk []byte, block_number int, block_chan chan *block, wg *sync.WaitGroup) {
-			var (
-				ciphertext *block
-				err        error
-			)
-			if block_number == num_chunks {
-				ciphertext, err = encryptChunk(key, base_nonce, chunk, block_number, true)
-			} else {
-				ciphertext, err = encryptChunk(key, base_nonce, chunk, block_number, false)
-			}
+			ciphertext, err := encryptChunk(key, base_nonce, chunk, block_number, block_number == num_chunks)
 			if err != nil {
-				ciphertext.err = err
+				ciphertext = &block{Index: block_number, err: err}
 			}
 			block_chan <- ciphertext
 			wg.Done()
@@ -107,26 +85,57 @@ func encryptToChan(filename string, key, base_nonce, file_data []byte, block_cha
 	}
 	wg.Wait()
 	done <- true
-	return nil
 }
 
 // Adds "base_nonce" to public facing version for testing purposes.
 func encrypt(filename string, key, base_nonce, file_data []byte) (ciphertext []byte, err error) {
+	name_chunk, err := prepareNameChunk(filename)
+	if err != nil {
+		return nil, err
+	}
+	return encryptWithNameChunk(name_chunk, key, base_nonce, file_data, ConstChunkSize)
+}
+
+// As encrypt, but if name_chunk is empty the file has no name, and its name
+// block holds an empty chunk.
+func encryptWithNameChunk(name_chunk, key, base_nonce, file_data []byte, chunk_size int) (ciphertext []byte, err error) {
 	if len(key) != 32 {
 		return nil, ErrBadKeyLength
 	}
-	// Pre-allocate space to help assemble the ciphertext afterwards..
-	num_chunks := numChunks(len(file_data), ConstChunkSize)
-	// Now allocate all but the last block. The last block is *appended* to the
-	// output, the others are *copied*.
-	// Each block requires 4 for the LE int length prefix, CHUNK_SIZE for the block,
-	// and 16 for the encryption overhead.
-	max_length := ConstFilenameBlockLength + (num_chunks * ConstBlockLength)
-	ciphertext = make([]byte, max_length-ConstBlockLength, max_length)
+	if !ValidChunkSize(chunk_size) {
+		return nil, ErrBadChunkSize
+	}
+	chunk_size = effectiveChunkSize(chunk_size)
+	if base_nonce == nil {
+		base_nonce, err = makeBaseNonce()
+		if err != nil {
+			return nil, err
+		}
+	}
+	fn_block, err := encryptChunk(key, base_nonce, name_chunk, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	// Even an empty file has one chunk, which is empty and flagged last.
+	chunks := chunkify(file_data, chunk_size)
+	name_length := len(fn_block.Block)
+	// Each block requires 4 for the LE int length prefix and 16 for the
+	// encryption overhead, around the chunk itself.
+	ciphertext = make([]byte, name_length+len(file_data)+len(chunks)*ConstBlockOverhead)
+	copy(ciphertext, fn_block.Block)
+	if len(chunks) == 1 {
+		// Small files and messages aren't worth fanning out.
+		this_block, err := encryptChunk(key, base_nonce, chunks[0], 1, true)
+		if err != nil {
+			return nil, err
+		}
+		copy(ciphertext[name_length:], this_block.Block)
+		return ciphertext, nil
+	}
 	// Now fan-out the job of encrypting the file...
 	block_chan := make(chan *block)
 	done_chan := make(chan bool)
-	go encryptToChan(filename, key, base_nonce, file_data, block_chan, done_chan)
+	go encryptToChan(key, base_nonce, chunks, block_chan, done_chan)
 	// And then fan-in re-assembly.
 	for {
 		select {
@@ -137,17 +146,8 @@ func encrypt(filename string, key, base_nonce, file_data []byte) (ciphertext []b
 					// This leaves the goroutines running but they ought to run out on their own?
 					return nil, this_block.err
 				}
-				if this_block.Index > 0 && this_block.Index < num_chunks {
-					// Find correct location for each chunk and copy in.
-					begins := this_block.BeginsLocation()
-					ends := begins + len(this_block.Block)
-					copy(ciphertext[begins:ends], this_block.Block)
-				} else if this_block.Index == num_chunks {
-					ciphertext = append(ciphertext, this_block.Block...)
-				} else {
-					// Filename chunk, special case.
-					copy(ciphertext, this_block.Block)
-				}
+				// Find correct location for each chunk and copy in.
+				copy(ciphertext[this_block.BeginsLocation(name_length, chunk_size):], this_block.Block)
 			}
 		case <-done_chan:
 			{
@@ -163,34 +163,40 @@ encrypt_finished:
 	return ciphertext, nil
 }
 
-// Encrypt symmetrically using this DecryptInfo object.
+// Encrypt symmetrically using this DecryptInfo object, in chunks of its
+// ChunkSize. Empty file_data is encrypted as a single empty chunk.
 func (self *DecryptInfo) Encrypt(filename string, file_data []byte) (ciphertext []byte, err error) {
-	if file_data == nil || len(file_data) == 0 {
-		return nil, ErrNilPlaintext
+	name_chunk, err := prepareNameChunk(filename)
+	if err != nil {
+		return nil, err
 	}
-	ciphertext, err = encrypt(filename, self.Key, self.BaseNonce, file_data)
+	ciphertext, err = encryptWithNameChunk(name_chunk, self.Key, self.BaseNonce, file_data, self.ChunkSize)
 	if err != nil {
 		return nil, err
 	}
 	return ciphertext, nil
 }
 
+// EncryptWithoutName encrypts symmetrically like Encrypt, but with an empty
+// name block in place of the 256-byte filename, for short messages that have
+// no name; Decrypt returns an empty filename. Only decoders that know of
+// unnamed files can read them.
+func (self *DecryptInfo) EncryptWithoutName(file_data []byte) (ciphertext []byte, err error) {
+	return encryptWithNameChunk(nil, self.Key, self.BaseNonce, file_data, self.ChunkSize)
+}
+
 // Generates random key/nonce, encrypts the data with it, and returns a DecryptInfo
 // object for storage, serialisation or deconstruction, along with the ciphertext.
 // According to the miniLock encryption protocol, the filename is encrypted in the
 // first block.
 func Encrypt(filename string, file_data []byte) (DI *DecryptInfo, ciphertext []byte, err error) {
-	DI = new(DecryptInfo)
-	DI.Key, err = makeSymmetricKey()
-	if err != nil {
-		return nil, nil, err
-	}
-	DI.BaseNonce, err = makeBaseNonce()
+	DI, err = NewDecryptInfo()
 	if err != nil {
 		return nil, nil, err
 	}
 	ciphertext, err = DI.Encrypt(filename, file_data)
 	if err != nil {
+		DI.Wipe()
 		return nil, nil, err
 	}
 	return DI, ciphertext, nil
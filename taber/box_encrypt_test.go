@@ -159,3 +159,81 @@ func Test_HighLevelBoxEncryption(t *testing.T) {
 		VPrint("Decrypted begins: ", string(plaintext[:200]))
 	}
 }
+
+func Test_EmptyAndUnnamedEncryption(t *testing.T) {
+	DI, err := NewDecryptInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DI.Wipe()
+	for _, tc := range []struct {
+		name      string
+		plaintext []byte
+		unnamed   bool
+		length    int
+	}{
+		// The name block, and one empty chunk flagged last.
+		{"empty", nil, false, ConstFilenameBlockLength + 20},
+		{"empty unnamed", []byte{}, true, 20 + 20},
+		{"short unnamed", []byte("New message."), true, 20 + 20 + 12},
+		{"exactly one chunk", large_plaintext[:ConstChunkSize], false, ConstFilenameBlockLength + ConstBlockLength},
+		{"unnamed, many chunks", large_plaintext, true, 20 + len(large_plaintext) + 11*20},
+	} {
+		var ciphertext []byte
+		if tc.unnamed {
+			ciphertext, err = DI.EncryptWithoutName(tc.plaintext)
+		} else {
+			ciphertext, err = DI.Encrypt("file.txt", tc.plaintext)
+		}
+		if err != nil {
+			t.Fatal("Couldn't encrypt ", tc.name, ": ", err)
+		}
+		if len(ciphertext) != tc.length || cap(ciphertext) != tc.length {
+			t.Error("Ciphertext for ", tc.name, " was ", len(ciphertext), " bytes (capacity ", cap(ciphertext), "), expected ", tc.length)
+		}
+		filename, plaintext, err := DI.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatal("Couldn't decrypt ", tc.name, ": ", err)
+		}
+		if (filename == "") != tc.unnamed || !bytes.Equal(plaintext, tc.plaintext) {
+			t.Error("Didn't round-trip ", tc.name, ", filename: ", filename)
+		}
+	}
+}
+
+func Test_ChunkSizes(t *testing.T) {
+	DI, err := NewDecryptInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DI.Wipe()
+	for _, chunkSize := range []int{MinChunkSize, 64 << 10, 4 << 20} {
+		DI.ChunkSize = chunkSize
+		ciphertext, err := DI.Encrypt("file.txt", large_plaintext)
+		if err != nil {
+			t.Fatal("Couldn't encrypt with chunk size ", chunkSize, ": ", err)
+		}
+		chunks := numChunks(len(large_plaintext), chunkSize)
+		if len(ciphertext) != ConstFilenameBlockLength+len(large_plaintext)+chunks*ConstBlockOverhead {
+			t.Error("Unexpected ciphertext length for chunk size ", chunkSize, ": ", len(ciphertext))
+		}
+		_, plaintext, err := DI.Decrypt(ciphertext)
+		if err != nil || !bytes.Equal(plaintext, large_plaintext) {
+			t.Error("Didn't round-trip with chunk size ", chunkSize, ": ", err)
+		}
+		// Decrypting with the wrong chunk size must fail, not garble.
+		wrong := DecryptInfo{Key: DI.Key, BaseNonce: DI.BaseNonce}
+		if _, _, err = wrong.Decrypt(ciphertext); err == nil {
+			t.Error("Decrypted chunk size ", chunkSize, " with the default chunk size")
+		}
+	}
+	for _, chunkSize := range []int{-1, 1, MinChunkSize - 1, MaxChunkSize + 1} {
+		DI.ChunkSize = chunkSize
+		if _, err = DI.Encrypt("file.txt", []byte("Short.")); err != ErrBadChunkSize {
+			t.Error("Expected ErrBadChunkSize for chunk size ", chunkSize, ", got: ", err)
+		}
+		if _, _, err = DI.Decrypt(make([]byte, 100)); err != ErrBadChunkSize {
+			t.Error("Expected ErrBadChunkSize decrypting chunk size ", chunkSize, ", got: ", err)
+		}
+	}
+}
@@ -1,26 +1,68 @@
 package taber
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+)
 
 var (
+	// ErrMalformed is matched by errors.Is for all errors caused by ciphertext
+	// or headers that don't follow the format.
+	ErrMalformed = errors.New("Malformed miniLock file")
+	// ErrTampered is matched by errors.Is for all errors caused by content that
+	// failed authentication, whether corrupted in transit or deliberately altered.
+	ErrTampered = errors.New("miniLock file failed authentication")
 	// ErrBadKeyLength is returned when encryption key must be 32 bytes long.
 	ErrBadKeyLength = errors.New("Encryption key must be 32 bytes long")
 	// ErrBadBaseNonceLength is returned when length of base_nonce must be 16.
 	ErrBadBaseNonceLength = errors.New("Length of base_nonce must be 16")
-	// ErrBadLengthPrefix is returned when block length prefixes indicate a length longer than the remaining ciphertext.
-	ErrBadLengthPrefix = errors.New("Block length prefixes indicate a length longer than the remaining ciphertext")
+	// ErrBadLengthPrefix is returned when block length prefixes are negative, longer than a chunk, or indicate a length longer than the remaining ciphertext.
+	ErrBadLengthPrefix error = &CategorisedError{"Block length prefixes indicate a length longer than the remaining ciphertext", ErrMalformed}
+	// ErrTruncatedCiphertext is returned when ciphertext ends part-way through a block, or holds no chunks.
+	ErrTruncatedCiphertext error = &CategorisedError{"Ciphertext is truncated", ErrMalformed}
 	// ErrBadPrefix is returned when chunk length prefix is longer than 4 bytes, would clobber ciphertext.
 	ErrBadPrefix = errors.New("Chunk length prefix is longer than 4 bytes, would clobber ciphertext")
 	// ErrBadBoxAuth is returned when authentication of box failed on opening.
-	ErrBadBoxAuth = errors.New("Authentication of box failed on opening")
+	ErrBadBoxAuth error = &CategorisedError{"Authentication of box failed on opening", ErrTampered}
 	// ErrBadBoxDecryptVars is returned when key or Nonce is not correct length to attempt decryption.
 	ErrBadBoxDecryptVars = errors.New("Key or Nonce is not correct length to attempt decryption")
 	// ErrBoxDecryptionEOP is returned when declared length of chunk would write past end of plaintext slice!.
-	ErrBoxDecryptionEOP = errors.New("Declared length of chunk would write past end of plaintext slice!")
+	ErrBoxDecryptionEOP error = &CategorisedError{"Declared length of chunk would write past end of plaintext slice!", ErrMalformed}
 	// ErrBoxDecryptionEOS is returned when chunk length is longer than expected slot in plaintext slice.
-	ErrBoxDecryptionEOS = errors.New("Chunk length is longer than expected slot in plaintext slice")
+	ErrBoxDecryptionEOS error = &CategorisedError{"Chunk length is longer than expected slot in plaintext slice", ErrMalformed}
+	// ErrBadChunkSize is returned when a chunk size is outside MinChunkSize and MaxChunkSize.
+	ErrBadChunkSize error = &CategorisedError{"Unsupported chunk size", ErrMalformed}
 	// ErrFilenameTooLong is returned when filename cannot be longer than 256 bytes.
 	ErrFilenameTooLong = errors.New("Filename cannot be longer than 256 bytes")
-	// ErrNilPlaintext is returned when asked to encrypt empty plaintext.
+	// ErrNilPlaintext was returned when asked to encrypt empty plaintext.
+	//
+	// Deprecated: empty plaintext is now encrypted as a single empty chunk, and
+	// this error is no longer returned.
 	ErrNilPlaintext = errors.New("Asked to encrypt empty plaintext")
 )
+
+// CategorisedError is a sentinel error that errors.Is also matches against a
+// broad category, such as ErrMalformed, so that callers needn't enumerate
+// every specific error.
+type CategorisedError struct {
+	Msg      string
+	Category error
+}
+
+func (e *CategorisedError) Error() string { return e.Msg }
+
+// Is reports whether target is the error's category.
+func (e *CategorisedError) Is(target error) bool { return target == e.Category }
+
+// ChunkAuthError is returned when a chunk of ciphertext fails authentication.
+// Index 0 is the filename chunk. It matches ErrBadBoxAuth and ErrTampered.
+type ChunkAuthError struct {
+	Index int
+}
+
+func (e *ChunkAuthError) Error() string {
+	return "Authentication of chunk " + strconv.Itoa(e.Index) + " failed on opening"
+}
+
+// Unwrap returns ErrBadBoxAuth.
+func (e *ChunkAuthError) Unwrap() error { return ErrBadBoxAuth }
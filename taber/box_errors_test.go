@@ -0,0 +1,23 @@
+package taber
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_ChunkAuthError(t *testing.T) {
+	DI, ciphertext, err := Encrypt("file.txt", []byte("Some file contents."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit in the ciphertext of the first content chunk.
+	ciphertext[ConstFilenameBlockLength+4] ^= 1
+	_, _, err = DI.Decrypt(ciphertext)
+	var chunkErr *ChunkAuthError
+	if !errors.As(err, &chunkErr) || chunkErr.Index != 1 {
+		t.Fatal("Expected a *ChunkAuthError for chunk 1, got: ", err)
+	}
+	if !errors.Is(err, ErrBadBoxAuth) || !errors.Is(err, ErrTampered) || errors.Is(err, ErrMalformed) {
+		t.Error("ChunkAuthError should match ErrBadBoxAuth and ErrTampered only: ", err)
+	}
+}
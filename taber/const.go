@@ -1,11 +1,32 @@
 package taber
 
 const (
-	// ConstChunkSize is the maximum length of chunks.
+	// ConstChunkSize is the default and original length of chunks; files
+	// encrypted with other chunk sizes must record theirs.
 	ConstChunkSize = 1048576
+	// MinChunkSize and MaxChunkSize bound the chunk sizes that may be chosen.
+	MinChunkSize = 4096
+	MaxChunkSize = 64 << 20
 	// ConstFilenameBlockLength is the length of the block that contains the filename,
 	// also the first block in the raw ciphertext.
 	ConstFilenameBlockLength = (256 + 16 + 4)
 	// ConstBlockLength is the length of the encrypted chunks.
-	ConstBlockLength = ConstChunkSize + 16 + 4
+	ConstBlockLength = ConstChunkSize + ConstBlockOverhead
+	// ConstBlockOverhead is the length of a block beyond its chunk: the length
+	// prefix and the encryption overhead.
+	ConstBlockOverhead = 16 + 4
 )
+
+// ValidChunkSize returns whether size may be used as a chunk size. Zero stands
+// for ConstChunkSize, and is valid.
+func ValidChunkSize(size int) bool {
+	return size == 0 || (size >= MinChunkSize && size <= MaxChunkSize)
+}
+
+// Zero stands for ConstChunkSize.
+func effectiveChunkSize(size int) int {
+	if size == 0 {
+		return ConstChunkSize
+	}
+	return size
+}
@@ -0,0 +1,106 @@
+package taber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// See the fuzz targets in the minilock package for how these are run; crashers
+// are kept in testdata/fuzz as regression tests.
+
+// Seed with the ciphertext of every miniLock file in binary_samples.
+func addSampleCiphertexts(f *testing.F) {
+	names, err := filepath.Glob(filepath.Join("..", "binary_samples", "*.minilock"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range names {
+		sample, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		// Skip "miniLock", the header length and the header.
+		if len(sample) < 12 {
+			continue
+		}
+		headerLength := int(binary.LittleEndian.Uint32(sample[8:12]))
+		if 12+headerLength <= len(sample) {
+			f.Add(sample[12+headerLength:])
+		}
+	}
+}
+
+// Walking arbitrary ciphertext must either fail or return blocks that exactly
+// cover it.
+func FuzzWalkCiphertext(f *testing.F) {
+	_, ciphertext, err := Encrypt("file.txt", []byte("Some file contents."))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(ciphertext)
+	addSampleCiphertexts(f)
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 0})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		blocks, err := walkCiphertext(data)
+		if err != nil {
+			return
+		}
+		var walked []byte
+		for _, b := range blocks {
+			walked = append(walked, b.Block...)
+		}
+		if !bytes.Equal(walked, data) {
+			t.Fatal("Walked blocks don't cover the ciphertext")
+		}
+	})
+}
+
+// Decrypting altered ciphertext with the right key must fail cleanly; nothing
+// but the original file may ever decrypt.
+func FuzzDecrypt(f *testing.F) {
+	plaintext := []byte("Some file contents.")
+	DI, ciphertext, err := Encrypt("file.txt", plaintext)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(ciphertext)
+	addSampleCiphertexts(f)
+	f.Add(ciphertext[:ConstFilenameBlockLength])
+	f.Add(append(append([]byte{}, ciphertext...), 0, 0))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		filename, decrypted, err := DI.Decrypt(data)
+		if err != nil {
+			return
+		}
+		if filename != "file.txt" || !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Altered ciphertext decrypted to something new: ", filename, decrypted)
+		}
+	})
+}
+
+// Any ID that imports must encode back to an ID for the same key.
+func FuzzFromID(f *testing.F) {
+	f.Add("Arv5UQQatYC7TvPVNWA6JLduApVMWoYV4f9DS2q5dRbt4")
+	f.Add("8nqVZubQa5abyNV1RhkW9Un8BcpFNqXGZaKQCe5obdFb6")
+	f.Add("")
+	f.Add("0OIl")
+	f.Fuzz(func(t *testing.T, ID string) {
+		keys, err := FromID(ID)
+		if err != nil {
+			return
+		}
+		encoded, err := keys.EncodeID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := FromID(encoded)
+		if err != nil || !bytes.Equal(again.Public, keys.Public) {
+			t.Fatal("Key didn't survive re-encoding: ", ID, encoded)
+		}
+	})
+}
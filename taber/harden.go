@@ -1,11 +1,68 @@
 package taber
 
 import (
+	"math"
+
 	"github.com/dchest/blake2s"
 	"golang.org/x/crypto/scrypt"
 )
 
+// KDFParams are the scrypt cost parameters used to harden a passphrase.
+type KDFParams struct {
+	LogN uint8 `json:"logN"`
+	R    int   `json:"r"`
+	P    int   `json:"p"`
+}
+
+// DefaultKDFParams matches the cost used by Harden: about 128MiB and a second or so.
+var DefaultKDFParams = KDFParams{LogN: 17, R: 8, P: 1}
+
+// MaxKDFMemory is the most memory, in bytes, that Validate lets scrypt use: as
+// much as LogN 20 with R 8, about four times DefaultKDFParams.
+const MaxKDFMemory = 1 << 30
+
+// MaxKDFParallelism is the highest P that Validate accepts. scrypt runs the P
+// passes one after another here, so P multiplies the time taken.
+const MaxKDFParallelism = 4
+
+// Memory returns the memory, in bytes, scrypt uses under params: 128·R·2^LogN,
+// or math.MaxInt64 for parameters far beyond any sane cost.
+func (params KDFParams) Memory() int64 {
+	if params.R < 1 {
+		return 0
+	}
+	if params.LogN > 40 || params.R > 1<<15 {
+		return math.MaxInt64
+	}
+	return 128 * int64(params.R) << params.LogN
+}
+
+// Validate checks that params are sane, and not so costly that an attacker
+// supplying them could exhaust memory or time: scrypt may use at most
+// MaxKDFMemory, over at most MaxKDFParallelism passes.
+func (params KDFParams) Validate() error {
+	if params.LogN < 10 || params.R < 1 || params.R > 32 || params.P < 1 || params.P > MaxKDFParallelism {
+		return ErrBadKDFParams
+	}
+	if params.LogN > 22 || params.Memory() > MaxKDFMemory {
+		return ErrBadKDFParams
+	}
+	return nil
+}
+
+// Harden derives 32 bytes of key material from passphrase, using salt (usually
+// an email) with scrypt. Callers should wipe the result when finished with it.
 func Harden(salt, passphrase string) ([]byte, error) {
+	return HardenWithParams([]byte(salt), passphrase, DefaultKDFParams)
+}
+
+// HardenWithParams is Harden with a binary salt, such as a random one stored
+// alongside the ciphertext, and chosen scrypt parameters.
+func HardenWithParams(salt []byte, passphrase string, params KDFParams) ([]byte, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 	pp_blake := blake2s.Sum256([]byte(passphrase))
-	return scrypt.Key(pp_blake[:], []byte(salt), 131072, 8, 1, 32)
+	defer WipeKeyArray(&pp_blake)
+	return scrypt.Key(pp_blake[:], salt, 1<<params.LogN, params.R, params.P, 32)
 }
@@ -0,0 +1,37 @@
+package taber
+
+import (
+	"testing"
+)
+
+func Test_KDFParamsValidate(t *testing.T) {
+	for name, params := range map[string]KDFParams{
+		"default":   DefaultKDFParams,
+		"cheapest":  {LogN: 10, R: 1, P: 1},
+		"sensitive": {LogN: 20, R: 8, P: 1},
+		"parallel":  {LogN: 17, R: 8, P: MaxKDFParallelism},
+	} {
+		if err := params.Validate(); err != nil {
+			t.Error("Expected ", name, " params to be accepted, got: ", err)
+		}
+	}
+	for name, params := range map[string]KDFParams{
+		"too cheap":         {LogN: 9, R: 8, P: 1},
+		"16 GiB":            {LogN: 22, R: 32, P: 16},
+		"over memory":       {LogN: 21, R: 8, P: 1},
+		"too parallel":      {LogN: 14, R: 8, P: MaxKDFParallelism + 1},
+		"overflowing":       {LogN: 200, R: 32, P: 1},
+		"no block size":     {LogN: 14, R: 0, P: 1},
+		"no parallelism":    {LogN: 14, R: 8, P: 0},
+		"negative rounds":   {LogN: 14, R: -8, P: 1},
+		"huge block size":   {LogN: 10, R: 1 << 20, P: 1},
+		"beyond any memory": {LogN: 63, R: 1, P: 1},
+	} {
+		if err := params.Validate(); err != ErrBadKDFParams {
+			t.Error("Expected ErrBadKDFParams for ", name, " params, got: ", err)
+		}
+	}
+	if m := (KDFParams{LogN: 20, R: 8, P: 1}).Memory(); m != MaxKDFMemory {
+		t.Error("Expected LogN 20, R 8 to need MaxKDFMemory, got: ", m)
+	}
+}
@@ -9,12 +9,14 @@ func (ks *Keys) Encrypt(plaintext, nonce []byte, to *Keys) (ciphertext []byte, e
 	if len(nonce) != 24 {
 		return nil, ErrBadNonceLength
 	}
+	if !ks.HasPrivate() {
+		return nil, ErrPrivateKeyOpOnly
+	}
 	ciphertext = make([]byte, 0, len(plaintext)+box.Overhead)
 	toArr := to.PublicArray()
 	defer WipeKeyArray(toArr)
-	pa := ks.PrivateArray()
-	defer WipeKeyArray(pa)
-	ciphertext = box.Seal(ciphertext, plaintext, nonceToArray(nonce), toArr, pa)
+	// Use the private key in place rather than leaving another copy around.
+	ciphertext = box.Seal(ciphertext, plaintext, nonceToArray(nonce), toArr, (*[32]byte)(ks.Private))
 	return ciphertext, nil
 }
 
@@ -24,14 +26,60 @@ func (ks *Keys) Decrypt(ciphertext, nonce []byte, from *Keys) (plaintext []byte,
 	if len(nonce) != 24 {
 		return nil, ErrBadNonceLength
 	}
+	if !ks.HasPrivate() {
+		return nil, ErrPrivateKeyOpOnly
+	}
+	if len(ciphertext) < box.Overhead {
+		return nil, ErrDecryptionAuthFail
+	}
 	plaintext = make([]byte, 0, len(ciphertext)-box.Overhead)
 	fromArr := from.PublicArray()
 	defer WipeKeyArray(fromArr)
-	pa := ks.PrivateArray()
-	defer WipeKeyArray(pa)
-	plaintext, ok = box.Open(plaintext[:], ciphertext, nonceToArray(nonce), fromArr, pa)
+	plaintext, ok = box.Open(plaintext[:], ciphertext, nonceToArray(nonce), fromArr, (*[32]byte)(ks.Private))
 	if !ok {
 		return nil, ErrDecryptionAuthFail
 	}
 	return plaintext, nil
 }
+
+// SharedKey is the key two keypairs share for boxes between them. Computing it
+// once and opening many boxes with it is much faster than Decrypt for each.
+type SharedKey struct {
+	key [32]byte
+}
+
+// Precompute returns the key shared between ks and peer, for opening boxes
+// from peer to ks (or sealing them from ks to peer). Wipe it when finished.
+func (ks *Keys) Precompute(peer *Keys) (*SharedKey, error) {
+	if !ks.HasPrivate() {
+		return nil, ErrPrivateKeyOpOnly
+	}
+	if !peer.HasPublic() {
+		return nil, ErrBadKeyLength
+	}
+	sk := new(SharedKey)
+	peerArr := peer.PublicArray()
+	defer WipeKeyArray(peerArr)
+	box.Precompute(&sk.key, peerArr, (*[32]byte)(ks.Private))
+	return sk, nil
+}
+
+// Open decrypts a box sealed with the shared key, as Decrypt does.
+func (sk *SharedKey) Open(ciphertext, nonce []byte) (plaintext []byte, err error) {
+	if len(nonce) != 24 {
+		return nil, ErrBadNonceLength
+	}
+	if len(ciphertext) < box.Overhead {
+		return nil, ErrDecryptionAuthFail
+	}
+	plaintext, ok := box.OpenAfterPrecomputation(make([]byte, 0, len(ciphertext)-box.Overhead), ciphertext, nonceToArray(nonce), &sk.key)
+	if !ok {
+		return nil, ErrDecryptionAuthFail
+	}
+	return plaintext, nil
+}
+
+// Wipe zeroes the shared key.
+func (sk *SharedKey) Wipe() {
+	WipeBytes(sk.key[:])
+}
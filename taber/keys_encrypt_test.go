@@ -51,3 +51,28 @@ func Test_CryptoRoundtrip(t *testing.T) {
 		t.Error("Decrypted message doesn't match original: '" + msg1 + "' vs '" + string(pt1) + "'")
 	}
 }
+
+func Test_PrecomputedOpen(t *testing.T) {
+	nonce := []byte("123456789012345678901234")
+	msg := "Attack at dawn!"
+	ct, err := testKey1.Encrypt([]byte(msg), nonce, testKey2.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := testKey2.Precompute(testKey1.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sk.Wipe()
+	pt, err := sk.Open(ct, nonce)
+	if err != nil || string(pt) != msg {
+		t.Error("Precomputed key didn't open box: ", string(pt), err)
+	}
+	ct[0] ^= 1
+	if _, err = sk.Open(ct, nonce); err != ErrDecryptionAuthFail {
+		t.Error("Expected ErrDecryptionAuthFail for a tampered box, got: ", err)
+	}
+	if _, err = testKey2.PublicOnly().Precompute(testKey1); err != ErrPrivateKeyOpOnly {
+		t.Error("Expected ErrPrivateKeyOpOnly precomputing with a public key, got: ", err)
+	}
+}
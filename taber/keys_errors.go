@@ -17,4 +17,6 @@ var (
 	ErrBadNonceLength = errors.New("Nonce length must be 24 length")
 	// ErrDecryptionAuthFail is returned when authentication of decryption using keys failed.
 	ErrDecryptionAuthFail = errors.New("Authentication of decryption using keys failed")
+	// ErrBadKDFParams is returned when scrypt parameters are out of the accepted range.
+	ErrBadKDFParams = errors.New("Scrypt parameters are out of the accepted range")
 )
@@ -3,6 +3,7 @@ package taber
 import (
 	"bytes"
 	"crypto/rand"
+	"io"
 
 	"github.com/cathalgarvey/base58"
 	"github.com/dchest/blake2s"
@@ -22,6 +23,9 @@ type Keys struct {
 
 	// Should always be full.
 	Public []byte
+
+	// Locked memory backing Private, for keys generated by this package.
+	secure *SecureBuffer
 }
 
 // Merely verifies whether a byte slice is 32 bytes long.
@@ -76,22 +80,18 @@ func (ks *Keys) PublicOnly() *Keys {
 
 // RandomKey generates a fully random Keys struct from a secure random source.
 func RandomKey() (*Keys, error) {
-	randBytes := make([]byte, 32)
-	read, err := rand.Read(randBytes)
-	if err != nil {
-		return nil, err
-	}
-	if read != 32 {
-		return nil, ErrInsufficientEntropy
-	}
-	randReader := bytes.NewReader(randBytes)
-	public, private, err := box.GenerateKey(randReader)
+	return RandomKeyFrom(rand.Reader)
+}
+
+// RandomKeyFrom generates a Keys struct from 32 bytes read from r, which must be
+// a secure random source outside of tests.
+func RandomKeyFrom(r io.Reader) (*Keys, error) {
+	seed, err := randBytesFrom(r, 32)
 	if err != nil {
 		return nil, err
 	}
-	// Always be explicit about public/private material in case struct is
-	// rearranged (/accidentally) later on
-	return &Keys{Private: private[:], Public: public[:]}, nil
+	defer WipeBytes(seed)
+	return keysFromSeed(seed)
 }
 
 // FromEmailAndPassphrase generates keys using a passphrase with a GUID as salt value.
@@ -106,12 +106,31 @@ func FromEmailAndPassphrase(guid, passphrase string) (*Keys, error) {
 	if err != nil {
 		return nil, err
 	}
-	scryptReader := bytes.NewReader(ppScrypt)
-	public, private, err := box.GenerateKey(scryptReader)
+	defer WipeBytes(ppScrypt)
+	return keysFromSeed(ppScrypt)
+}
+
+// FromPrivate creates a Keys struct from a 32-byte private key, deriving the
+// public key from it. The private key is copied into locked memory; the caller
+// remains responsible for wiping the slice passed in.
+func FromPrivate(private []byte) (*Keys, error) {
+	if len(private) != 32 {
+		return nil, ErrBadKeyLength
+	}
+	return keysFromSeed(private)
+}
+
+// Generate a box keypair from 32 bytes of seed material, keeping the private
+// key in locked memory and wiping the intermediate copy.
+func keysFromSeed(seed []byte) (*Keys, error) {
+	public, private, err := box.GenerateKey(bytes.NewReader(seed))
 	if err != nil {
 		return nil, err
 	}
-	return &Keys{Private: private[:], Public: public[:]}, nil
+	secure := secureCopy(private[:])
+	// Always be explicit about public/private material in case struct is
+	// rearranged (/accidentally) later on
+	return &Keys{Private: secure.Bytes(), Public: public[:], secure: secure}, nil
 }
 
 // FromID creates a Keys struct from a checksummed ID string.
@@ -176,14 +195,16 @@ func (ks *Keys) EncodeID() (string, error) {
 	return string(id), nil
 }
 
-// Wipe overwrites memory containing key material; calling this method when
-// finished with a key is strongly advised to prevent compromise.
+// Wipe zeroes memory containing key material; calling this method when
+// finished with a key is strongly advised to prevent compromise. Keys
+// generated by this package also have their locked memory released.
+// Copies made with PrivateArray are not affected.
 func (ks *Keys) Wipe() (err error) {
-	if ks.HasPrivate() {
-		err = wipeByteSlice(ks.Private)
-		if err != nil {
-			return err
-		}
+	if ks.secure != nil {
+		ks.secure.Wipe()
+	} else {
+		WipeBytes(ks.Private)
 	}
-	return wipeByteSlice(ks.Public)
+	WipeBytes(ks.Public)
+	return nil
 }
@@ -0,0 +1,79 @@
+package taber
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// SecureBuffer holds key material in memory that is locked against being
+// swapped to disk, where the platform allows it. Each buffer occupies whole
+// pages of its own, so that unlocking one buffer never unlocks another's
+// memory. Go's garbage collector does not move heap memory, so slices of the
+// buffer remain valid (if zeroed) after Wipe; this is deliberate, so that a
+// stale alias reads zeroes rather than crashing the process.
+type SecureBuffer struct {
+	// Whole, page-aligned pages locked for this buffer.
+	pages []byte
+	// The bytes actually requested, a prefix of pages.
+	buf    []byte
+	locked bool
+}
+
+// NewSecureBuffer allocates a zeroed, locked buffer of size bytes. Locking is
+// best-effort: if the platform or resource limits forbid it the buffer is
+// still usable, and Locked reports false.
+func NewSecureBuffer(size int) *SecureBuffer {
+	pageSize := os.Getpagesize()
+	numPages := (size + pageSize - 1) / pageSize
+	if numPages == 0 {
+		numPages = 1
+	}
+	raw := make([]byte, (numPages+1)*pageSize)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&raw[0])) % uintptr(pageSize)); rem != 0 {
+		offset = pageSize - rem
+	}
+	sb := &SecureBuffer{pages: raw[offset : offset+numPages*pageSize]}
+	sb.buf = sb.pages[:size:size]
+	sb.locked = lockMemory(sb.pages)
+	return sb
+}
+
+// Bytes returns the buffer's contents. The returned slice is the buffer
+// itself, not a copy.
+func (sb *SecureBuffer) Bytes() []byte {
+	return sb.buf
+}
+
+// Locked returns whether the buffer's memory is locked against swapping.
+func (sb *SecureBuffer) Locked() bool {
+	return sb.locked
+}
+
+// Wipe zeroes the buffer and unlocks its memory. The buffer must not be used
+// to hold key material afterwards.
+func (sb *SecureBuffer) Wipe() {
+	WipeBytes(sb.pages)
+	if sb.locked {
+		unlockMemory(sb.pages)
+		sb.locked = false
+	}
+}
+
+// Copy key material into a new SecureBuffer and wipe the original.
+func secureCopy(src []byte) *SecureBuffer {
+	sb := NewSecureBuffer(len(src))
+	copy(sb.buf, src)
+	WipeBytes(src)
+	return sb
+}
+
+// WipeBytes zeroes b in a way the compiler will not optimise away. Use it for
+// temporary copies of key material, such as encoded file info.
+func WipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
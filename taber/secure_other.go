@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package taber
+
+func lockMemory(b []byte) bool {
+	return false
+}
+
+func unlockMemory(b []byte) {}
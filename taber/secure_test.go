@@ -0,0 +1,57 @@
+package taber
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+func Test_SecureBuffer(t *testing.T) {
+	sb := NewSecureBuffer(32)
+	if len(sb.Bytes()) != 32 {
+		t.Fatal("Expected a 32 byte buffer, got:", len(sb.Bytes()))
+	}
+	if uintptr(unsafe.Pointer(&sb.Bytes()[0]))%uintptr(os.Getpagesize()) != 0 {
+		t.Error("Secure buffer is not page aligned")
+	}
+	VPrint("Secure buffer locked:", sb.Locked())
+	copy(sb.Bytes(), []byte("01234567890123456789012345678901"))
+	alias := sb.Bytes()
+	sb.Wipe()
+	if !bytes.Equal(alias, make([]byte, 32)) {
+		t.Error("Wipe did not zero the buffer:", alias)
+	}
+	if sb.Locked() {
+		t.Error("Buffer still reports locked memory after Wipe")
+	}
+}
+
+func Test_KeysWipe(t *testing.T) {
+	keys, err := RandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.secure == nil {
+		t.Fatal("RandomKey did not put the private key in a secure buffer")
+	}
+	private := keys.Private
+	if err = keys.Wipe(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(private, make([]byte, 32)) || !bytes.Equal(keys.Public, make([]byte, 32)) {
+		t.Error("Wipe did not zero key material")
+	}
+}
+
+func Test_PublicOnlyEncrypt(t *testing.T) {
+	keys, err := RandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keys.Wipe()
+	_, err = keys.PublicOnly().Encrypt([]byte("plaintext"), make([]byte, 24), keys)
+	if err != ErrPrivateKeyOpOnly {
+		t.Error("Expected ErrPrivateKeyOpOnly encrypting with a public-only key, got:", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin
+
+package taber
+
+import "syscall"
+
+func lockMemory(b []byte) bool {
+	return syscall.Mlock(b) == nil
+}
+
+func unlockMemory(b []byte) {
+	syscall.Munlock(b)
+}
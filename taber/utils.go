@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"io"
 
 	"golang.org/x/crypto/nacl/secretbox"
 )
@@ -27,17 +28,22 @@ func chunkify(data []byte, chunkLength int) [][]byte {
 	return output
 }
 
+// There is always at least one chunk, which is empty for empty data.
 func numChunks(dataLength, chunkLength int) int {
 	numChunks := dataLength / chunkLength
-	if (dataLength % chunkLength) > 0 {
+	if (dataLength%chunkLength) > 0 || numChunks == 0 {
 		numChunks = numChunks + 1
 	}
 	return numChunks
 }
 
 func randBytes(i int) ([]byte, error) {
+	return randBytesFrom(rand.Reader, i)
+}
+
+func randBytesFrom(r io.Reader, i int) ([]byte, error) {
 	randBytes := make([]byte, i)
-	read, err := rand.Read(randBytes)
+	read, err := io.ReadFull(r, randBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -55,22 +61,12 @@ func makeFullNonce() ([]byte, error) {
 	return randBytes(24)
 }
 
-func makeSymmetricKey() ([]byte, error) {
-	return randBytes(32)
-}
-
 func nonceToArray(n []byte) *[24]byte {
 	na := new([24]byte)
 	copy(na[:], n)
 	return na
 }
 
-func keyToArray(k []byte) *[32]byte {
-	ka := new([32]byte)
-	copy(ka[:], k)
-	return ka
-}
-
 func prefixToBlockL(prefix int) int {
 	return prefix + secretbox.Overhead + 4
 }
@@ -94,25 +90,10 @@ func fromLittleEndian(buf []byte) (int32, error) {
 	return output, nil
 }
 
-// WipeKeyArray fills a 32-byte array such as used for key material with random bytes.
+// WipeKeyArray zeroes a 32-byte array such as used for key material.
 // It is intended for use with defer to wipe temporary arrays used to contain key material.
+// It never fails; the error return is kept for compatibility.
 func WipeKeyArray(arr *[32]byte) error {
-	return wipeByteSlice(arr[:])
-}
-
-func wipeByteSlice(bs []byte) error {
-	var (
-		bsLen int
-		read  int
-		err   error
-	)
-	bsLen = len(bs)
-	read, err = rand.Read(bs)
-	if err != nil {
-		return err
-	}
-	if read != bsLen {
-		return ErrInsufficientEntropy
-	}
+	WipeBytes(arr[:])
 	return nil
 }
@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"io"
 )
 
+// All randomness used in encryption is read from randReader, so that tests can
+// substitute a deterministic source and compare whole files.
+var randReader io.Reader = rand.Reader
+
 func randBytes(i int) ([]byte, error) {
 	randBytes := make([]byte, i)
-	read, err := rand.Read(randBytes)
+	read, err := io.ReadFull(randReader, randBytes)
 	if err != nil {
 		return nil, err
 	}
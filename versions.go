@@ -0,0 +1,143 @@
+package minilock
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Header versions. Files are written as HeaderVersionFMiniLock unless another
+// version is asked for.
+const (
+	// HeaderVersionMiniLock is the original miniLock header, whose entries may
+	// lack a sender identity. Files written by go-minilock before header
+	// versions were checked also use it, with signed entries.
+	HeaderVersionMiniLock = 1
+	// HeaderVersionFMiniLock is the fminilock header, whose entries must all be
+	// signed by a sender identity or explicitly anonymous.
+	HeaderVersionFMiniLock = 2
+	// HeaderVersionBinary is the fminilock header in a compact binary layout
+	// rather than JSON, for files to many recipients.
+	HeaderVersionBinary = 3
+	// HeaderVersionFramed is the fminilock header for files whose payload is
+	// framed, carrying metadata ahead of the contents. Files are written with
+	// it instead of HeaderVersionFMiniLock when they need a frame, so that
+	// readers which don't know frames refuse them rather than misread them.
+	HeaderVersionFramed = 4
+	// HeaderVersionBinaryFramed is HeaderVersionBinary for framed payloads.
+	HeaderVersionBinaryFramed = 5
+)
+
+// headerFormat describes how to read and write one version of the miniLock
+// header.
+type headerFormat struct {
+	name string
+	// binary headers start with their version byte rather than JSON.
+	binary bool
+	// decode parses the header bytes following the length prefix.
+	decode func(headerBytes []byte) (*miniLockv1Header, error)
+	// encode appends the header to into.
+	encode func(hdr *miniLockv1Header, into []byte) ([]byte, error)
+	// encodedLength is the length encode will append, computed without
+	// encoding.
+	encodedLength func(hdr *miniLockv1Header) int
+	// legacyEntries is whether entries without a sender identity may appear.
+	legacyEntries bool
+	// passphraseSlots is whether the header may hold passphrase slots.
+	passphraseSlots bool
+	// framed is whether the payload is framed; see payload.go.
+	framed bool
+	// framedVersion is the version written instead when the payload needs a
+	// frame, or 0 if there is none.
+	framedVersion int
+}
+
+// The registry of known header versions, which parsing dispatches on.
+var headerFormats = map[int]headerFormat{
+	HeaderVersionMiniLock: {
+		name:          "miniLock",
+		decode:        decodeJSONHeader,
+		encode:        encodeJSONHeader,
+		encodedLength: jsonHeaderLength,
+		legacyEntries: true,
+	},
+	HeaderVersionFMiniLock: {
+		name:            "fminilock",
+		decode:          decodeJSONHeader,
+		encode:          encodeJSONHeader,
+		encodedLength:   jsonHeaderLength,
+		passphraseSlots: true,
+		framedVersion:   HeaderVersionFramed,
+	},
+	HeaderVersionBinary: {
+		name:          "fminilock-binary",
+		binary:        true,
+		decode:        decodeBinaryHeader,
+		encode:        encodeBinaryHeader,
+		encodedLength: binaryHeaderLength,
+		framedVersion: HeaderVersionBinaryFramed,
+	},
+	HeaderVersionFramed: {
+		name:            "fminilock-framed",
+		decode:          decodeJSONHeader,
+		encode:          encodeJSONHeader,
+		encodedLength:   jsonHeaderLength,
+		passphraseSlots: true,
+		framed:          true,
+	},
+	HeaderVersionBinaryFramed: {
+		name:          "fminilock-binary-framed",
+		binary:        true,
+		decode:        decodeBinaryHeader,
+		encode:        encodeBinaryHeader,
+		encodedLength: binaryHeaderLength,
+		framed:        true,
+	},
+}
+
+// VersionError is returned, within a *HeaderError, when a header has a version
+// this package doesn't know. It matches ErrUnknownVersion.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return ErrUnknownVersion.Error() + " " + strconv.Itoa(e.Version)
+}
+
+// Unwrap returns ErrUnknownVersion.
+func (e *VersionError) Unwrap() error { return ErrUnknownVersion }
+
+// Looks up the format of a header: JSON headers have their version in a
+// field, and binary headers in their first byte.
+func headerFormatOf(headerBytes []byte) (headerFormat, error) {
+	var probe struct {
+		Version int `json:"version"`
+	}
+	isBinary := len(headerBytes) > 0 && !isJSONStart(headerBytes[0])
+	if isBinary {
+		probe.Version = int(headerBytes[0])
+	} else if err := json.Unmarshal(headerBytes, &probe); err != nil {
+		return headerFormat{}, err
+	}
+	format, ok := headerFormats[probe.Version]
+	if !ok || format.binary != isBinary {
+		return headerFormat{}, &VersionError{probe.Version}
+	}
+	return format, nil
+}
+
+// Whether b can start a JSON header; no binary header version may be one of
+// these bytes.
+func isJSONStart(b byte) bool {
+	switch b {
+	case '{', ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+// format returns the header's entry in the version registry; parsed headers
+// always have one.
+func (hdr *miniLockv1Header) format() headerFormat {
+	return headerFormats[hdr.Version]
+}
@@ -0,0 +1,90 @@
+package minilock
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// Re-encode a parsed file with its JSON header changed, whatever its version.
+func restuff(t *testing.T, hdr *miniLockv1Header, ciphertext []byte) []byte {
+	encHeader, err := encodeJSONHeader(hdr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdrLengthLE, err := toLittleEndian(int32(len(encHeader)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := append([]byte(magicBytes), hdrLengthLE...)
+	out = append(out, encHeader...)
+	return append(out, ciphertext...)
+}
+
+func Test_HeaderVersions(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	sender, _ := EphemeralKey()
+	replyTo, _ := EphemeralKey()
+	encrypted, err := EncryptFileContents("file.txt", []byte("Some file contents."), sender, replyTo, testKey1, recipient.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, ciphertext, err := ParseFileContents(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := hdr.Info(); info.Version != HeaderVersionFMiniLock || info.Format != "fminilock" {
+		t.Error("New files should have an fminilock header, got: ", info)
+	}
+
+	// Files claiming the old version still decrypt, as go-minilock wrote them.
+	hdr.Version = HeaderVersionMiniLock
+	msg, err := DecryptMessage(restuff(t, hdr, ciphertext), recipient)
+	if err != nil || msg.Header.Format != "miniLock" {
+		t.Error("Couldn't decrypt file with a version 1 header: ", err)
+	}
+
+	for _, version := range []int{0, 3, -1} {
+		hdr.Version = version
+		_, _, err = ParseFileContents(restuff(t, hdr, ciphertext))
+		var versionErr *VersionError
+		if !errors.As(err, &versionErr) || versionErr.Version != version || !errors.Is(err, ErrUnknownVersion) || !errors.Is(err, ErrMalformed) {
+			t.Error("Expected unknown version error for version ", version, ", got: ", err)
+		}
+	}
+}
+
+// An fminilock header can't carry legacy entries, even in compatibility mode;
+// the same entry in a header relabelled as miniLock is accepted only there.
+func Test_FMiniLockRejectsUnsignedEntries(t *testing.T) {
+	recipient, _ := EphemeralKey()
+	sender, _ := EphemeralKey()
+	hdr, ephem, err := prepareNewHeader(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := makeFullNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := &FileInfo{FileKey: make([]byte, 32), FileNonce: make([]byte, 16), FileHash: make([]byte, 32)}
+	DI, err := NewAnonymousDecryptInfoEntry(nonce, fi, sender, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	DI.Anonymous = false
+	diEnc, err := EncryptDecryptInfo(DI, nonce, ephem, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr.DecryptInfo[base64.StdEncoding.EncodeToString(nonce)] = diEnc
+
+	_, err = hdr.DecryptMessage(nil, recipient, AllowLegacy())
+	if !errors.Is(err, ErrUnsignedEntry) || !errors.Is(err, ErrMalformed) {
+		t.Error("Expected ErrUnsignedEntry for a legacy entry in an fminilock header, got: ", err)
+	}
+	hdr.Version = HeaderVersionMiniLock
+	if _, err = hdr.DecryptMessage(nil, recipient); err != ErrLegacyFile {
+		t.Error("Expected ErrLegacyFile for a legacy entry in a miniLock header, got: ", err)
+	}
+}